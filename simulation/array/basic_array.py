@@ -1,3 +1,6 @@
+import numpy as np
+from numpy.polynomial import Polynomial
+
 from simulation.array.base_array import BaseArray
 
 
@@ -34,6 +37,95 @@ class BasicArray(BaseArray):
 
         return produced_power
 
+    @staticmethod
+    def calculate_temperature_derated_power(solar_irradiance, cell_temperature, panel_efficiency,
+                                            temperature_coefficient, panel_size, incidence_angles=None,
+                                            iam_coefficients=None):
+        """
+        returns the power produced by a solar panel in watts, with the efficiency of the solar
+            cells derated for cell temperatures away from the 25°C standard test condition
+
+        :param solar_irradiance: (float[N]) global horizontal irradiance (GHI) in W/m2. If incidence_angles
+            is passed, this should instead be the irradiance measured normal to the sun's rays (DNI)
+        :param cell_temperature: (float[N]) temperature of the solar cells in °C
+        :param panel_efficiency: (float) the efficiency of the solar cells at 25°C as a number
+            between 0 and 1
+        :param temperature_coefficient: (float) relative change in efficiency per °C above 25°C,
+            which is negative for silicon cells (around -0.004)
+        :param panel_size: (float) the area of the solar panels in m2
+        :param incidence_angles: (float[N]) optional angle between the sun's rays and the normal of the
+            panels in degrees, where 0 means the sun is directly in front of the panels and anything
+            above 90 means the sun is behind them. Applies a cosine loss to the irradiance
+        :param iam_coefficients: (float[M]) optional coefficients, in increasing order, of a polynomial of
+            the incidence angle in degrees that models extra reflection losses at shallow angles (the
+            incidence angle modifier). Only used along with incidence_angles
+
+        :returns: (float[N]) the power produced by a solar panel in W, which is never negative
+        """
+
+        derated_efficiency = panel_efficiency * (1 + temperature_coefficient * (cell_temperature - 25))
+        produced_power = solar_irradiance * derated_efficiency * panel_size
+
+        if incidence_angles is not None:
+            # no power is collected when the sun is behind the panels
+            incidence_factor = np.clip(np.cos(np.radians(incidence_angles)), a_min=0, a_max=None)
+
+            if iam_coefficients is not None:
+                incidence_angle_modifier = Polynomial(iam_coefficients)(incidence_angles)
+                incidence_factor = incidence_factor * np.clip(incidence_angle_modifier, a_min=0, a_max=1)
+
+            produced_power = produced_power * incidence_factor
+
+        return np.clip(produced_power, a_min=0, a_max=None)
+
+    @staticmethod
+    def apply_derate(produced_power, derate_factors):
+        """
+        returns the power produced by the solar panels once losses that vary over the race, such as
+            soiling (dust building up, then being washed off by rain) and cell mismatch, are applied
+
+        :param produced_power: (float[N]) power produced by the solar panels in W, such as from
+            calculate_temperature_derated_power
+        :param derate_factors: (float[N]) fraction of the power that remains at each moment, between 0 and 1
+
+        :returns: (float[N]) the derated power produced by the solar panels in W
+        """
+
+        derate_factors = np.asarray(derate_factors, dtype=float)
+
+        if np.any((derate_factors < 0) | (derate_factors > 1)):
+            raise ValueError(f"\"derate_factors\" argument is invalid. All factors must be between 0 and 1, "
+                             f"but the range is [{np.min(derate_factors)}, {np.max(derate_factors)}]")
+
+        return produced_power * derate_factors
+
+    @staticmethod
+    def calculate_cell_temperature(ambient_temperature, solar_irradiance, noct=45, airspeeds=None,
+                                   convective_coefficient=0.27):
+        """
+        returns the temperature of the solar cells in °C, estimated from the ambient temperature
+            and the irradiance using the nominal operating cell temperature (NOCT) model
+
+        :param ambient_temperature: (float[N]) temperature of the air surrounding the panels in °C
+        :param solar_irradiance: (float[N]) global horizontal irradiance (GHI) in W/m2
+        :param noct: (float) temperature the cells reach at 800W/m2 irradiance and 20°C ambient
+            temperature, as given on the cell datasheet, in °C
+        :param airspeeds: (float[N]) effective airspeed over the panels in m/s. If given, air flowing
+            over the panels carries heat away, and the cells run cooler the faster the car goes
+        :param convective_coefficient: (float) how quickly the panels' heat loss coefficient grows with
+            airspeed, relative to its value in still air, in s/m. The default is U1 / U0 from the Faiman model
+
+        :returns: (float[N]) the temperature of the solar cells in °C
+        """
+
+        heating = (solar_irradiance / 800) * (noct - 20)
+
+        if airspeeds is not None:
+            # the heat loss coefficient U = U0 * (1 + convective_coefficient * airspeed)
+            heating = heating / (1 + convective_coefficient * np.clip(airspeeds, a_min=0, a_max=None))
+
+        return ambient_temperature + heating
+
     def update(self, tick):
         """
         updates solar array model for a single tick
@@ -54,7 +146,8 @@ class BasicArray(BaseArray):
 
         :param solar_irradiance: (float[N]) the global horizontal irradiance(GHI) at
             each moment experienced by the vehicle, in W/m2
-        :param tick: (float) the duration of a time step in seconds
+        :param tick: (float or float[N]) the duration of each time step in seconds, either
+            uniform or per time step
 
         returns: (float[N]) array of energy produced by the solar panels on the vehicle
             in Joules
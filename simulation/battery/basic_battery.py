@@ -99,6 +99,75 @@ class BasicBattery(BaseBattery):
 
         return super().discharge(energy / 3600)
 
+    @staticmethod
+    def calculate_resistive_losses(currents, temperatures, temperature_breakpoints, resistance_breakpoints):
+        """
+        Calculates the power lost as heat in the pack's internal resistance (I^2 * R), which grows as the
+        pack gets colder. Resistances between the measured temperatures are linearly interpolated, and
+        temperatures outside of them are clamped to the nearest measurement.
+
+        :param currents: (float[N]) current drawn from the battery at each time step (A), such as
+            power / pack voltage
+        :param temperatures: (float[N]) temperature of the battery at each time step (°C)
+        :param temperature_breakpoints: (float[M]) increasing temperatures that the resistance is known at (°C)
+        :param resistance_breakpoints: (float[M]) internal resistance of the pack at each temperature (Ω)
+
+        :return resistive_losses: a NumPy array containing the power lost to internal resistance at each
+        time step (W), to be subtracted from the usable energy
+        """
+
+        internal_resistances = np.interp(temperatures, temperature_breakpoints, resistance_breakpoints)
+
+        return np.square(currents) * internal_resistances
+
+    @staticmethod
+    def calculate_minimum_soc(soc_array):
+        """
+        Finds the point of deepest battery depletion over the race.
+
+        :param soc_array: a NumPy array containing the battery state of charge at each time step, as returned by
+        update_array
+
+        :return minimum_soc: the lowest state of charge reached (0.00 - 1.00)
+
+        :return minimum_soc_index: the index of the first time step at which the lowest state of charge is reached
+        """
+
+        minimum_soc_index = int(np.argmin(soc_array))
+
+        return soc_array[minimum_soc_index], minimum_soc_index
+
+    @staticmethod
+    def calculate_charge_time(state_of_charge, target_state_of_charge, energy_capacity, array_powers, tick=1):
+        """
+        Calculates how long the car must stay parked at a stop to charge from the solar array up to a target
+        state of charge. The available charge time is limited to the length of array_powers.
+
+        :param state_of_charge: battery state of charge at the start of the stop (0.00 - 1.00)
+        :param target_state_of_charge: battery state of charge to charge up to (0.00 - 1.00)
+        :param energy_capacity: energy capacity of the battery (Wh)
+        :param array_powers: a NumPy array containing the power produced by the parked solar array at each time
+        step (W), which varies with the sun
+        :param tick: length of each time step (in seconds)
+
+        :return charge_time: time needed to reach target_state_of_charge (in seconds), or -1 if it cannot be
+        reached within the length of array_powers
+        """
+
+        required_energy = (target_state_of_charge - state_of_charge) * energy_capacity
+
+        if required_energy <= 0:
+            return 0
+
+        # divide by 3600 to convert from joules to watt-hours
+        charged_energy = np.cumsum(np.asarray(array_powers, dtype=float) * tick) / 3600
+        reached_indices = np.flatnonzero(charged_energy >= required_energy)
+
+        if reached_indices.size == 0:
+            return -1
+
+        return (reached_indices[0] + 1) * tick
+
     def update_array(self, cumulative_energy_array):
         """
         Performs energy calculations with NumPy arrays
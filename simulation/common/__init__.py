@@ -2,4 +2,5 @@ from simulation.common.consumer import Consumer
 from simulation.common.producer import Producer
 from simulation.common.storage import Storage
 from simulation.common.exceptions import BatteryEmptyError
+from simulation.common.exceptions import NonFiniteInputError
 from simulation.common.helpers import timeit
@@ -3,5 +3,12 @@
 # Radius of the Earth (m)
 EARTH_RADIUS = 6371009
 
+# Semi-major axis (m) and first eccentricity squared of the WGS84 ellipsoid
+WGS84_SEMI_MAJOR_AXIS = 6378137
+WGS84_ECCENTRICITY_SQUARED = 0.00669437999014
+
+# Irradiance of sunlight at the mean Earth-Sun distance (W/m^2)
+SOLAR_CONSTANT = 1361
+
 # Acceleration caused by gravity (m/s^2)
 ACCELERATION_G = 9.81
@@ -7,3 +7,17 @@ Contains all the custom exceptions used in the simulation package
 
 class BatteryEmptyError(Exception):
     pass
+
+
+class NonFiniteInputError(Exception):
+    """
+    Raised when an input array contains NaN or infinite values
+
+    :param array_name: (string) name of the offending array
+    :param index: (int) index of the first NaN or infinite value in the flattened array
+    """
+
+    def __init__(self, array_name, index):
+        super().__init__(f"ERROR: {array_name} contains a non-finite value at index {index}.\n")
+        self.array_name = array_name
+        self.index = index
@@ -8,6 +8,7 @@ from _datetime import date
 from numba import jit, njit
 
 from simulation.common import constants
+from simulation.common.exceptions import NonFiniteInputError
 
 
 """
@@ -15,6 +16,10 @@ Description: contains the simulation's helper functions.
 """
 
 
+# run time in seconds of the most recent call to each function decorated with timeit, keyed by function name
+last_timings = {}
+
+
 def timeit(func):
     @functools.wraps(func)
     def wrapper_timer(*args, **kwargs):
@@ -23,12 +28,41 @@ def timeit(func):
         value = func(*args, **kwargs)
         stop = timer.perf_counter()
         run_time = stop - start
+        last_timings[func.__name__] = run_time
         print(f"Finished {func.__name__!r} in {run_time:.3f}s. \n")
         return value
 
     return wrapper_timer
 
 
+def get_last_timing(function_name):
+    """
+    Returns how long the most recent call to a function decorated with timeit took, so that
+        run times can be collected while profiling instead of only being printed.
+
+    :param function_name: (string) name of the decorated function, e.g. "calculate_array_GHI"
+
+    :returns: (float) run time in seconds, or None if the function has not been called yet
+    """
+
+    return last_timings.get(function_name)
+
+
+def check_finite(array, array_name):
+    """
+    Raises a NonFiniteInputError if an array contains NaN or infinite values. Comparisons against NaN are
+        always False, so searches such as np.argmin would otherwise silently return a misleading result.
+
+    :param array: (float[N]) array to be checked
+    :param array_name: (string) name of the array, used to report which input was bad
+    """
+
+    non_finite_indices = np.flatnonzero(~np.isfinite(array))
+
+    if non_finite_indices.size != 0:
+        raise NonFiniteInputError(array_name, non_finite_indices[0])
+
+
 def date_from_unix_timestamp(unix_timestamp):
     return datetime.utcfromtimestamp(unix_timestamp).strftime('%Y-%m-%d %H:%M:%S')
 
@@ -93,6 +127,548 @@ def add_acceleration(input_array, acceleration):
     return input_array
   
   
+def merge_hold_ranges(hold_ranges):
+    """
+    Merges overlapping or nested holds into a sorted list of disjoint holds, so that time covered by
+        more than one hold is only counted once.
+
+    :param hold_ranges: (int[M][2]) [start, end) second indices of each hold
+
+    :returns: a sorted list of disjoint (start, end) second indices of the merged holds
+    """
+
+    merged_ranges = []
+
+    for start, end in sorted((int(start), int(end)) for start, end in hold_ranges):
+        if end <= start:
+            continue
+
+        if merged_ranges and start <= merged_ranges[-1][1]:
+            merged_ranges[-1] = (merged_ranges[-1][0], max(merged_ranges[-1][1], end))
+        else:
+            merged_ranges.append((start, end))
+
+    return merged_ranges
+
+
+def apply_overnight_holds(speed_kmh, hold_ranges):
+    """
+    Inserts mandatory stops at fixed clock times (such as ASC's overnight holds) into a speed array.
+        Driving that would have happened during a hold is pushed back until the hold ends, so every
+        later part of the speed profile occurs later by the length of the hold.
+
+    :param speed_kmh: (float[N]) speed of the car at each second in km/h
+    :param hold_ranges: (int[M][2]) [start, end) second indices of each hold
+
+    :returns: (float[N]) speed array with zeros during every hold, truncated to the original length
+    """
+
+    speed_kmh = np.asarray(speed_kmh, dtype=float)
+    result = []
+    source_index = 0
+    result_length = 0
+
+    for start, end in merge_hold_ranges(hold_ranges):
+        # drive up to the start of the hold, then wait until it ends
+        driven = speed_kmh[source_index:source_index + start - result_length]
+        result.append(driven)
+        result.append(np.zeros(end - result_length - len(driven)))
+
+        source_index += len(driven)
+        result_length = end
+
+    result.append(speed_kmh[source_index:])
+
+    return np.concatenate(result)[:len(speed_kmh)]
+
+
+def calculate_total_stop_time(stop_durations, hold_ranges=None):
+    """
+    Calculates the fixed time that mandatory stops take out of the race, so that the remaining
+        driving time can be budgeted.
+
+    :param stop_durations: (int[M]) duration of each mandatory stop (such as at a checkpoint) in seconds
+    :param hold_ranges: (int[K][2]) optional [start, end) second indices of each overnight hold, in the
+        same form as apply_overnight_holds. Overlapping holds are merged, as in apply_overnight_holds
+
+    :returns: (int) total time spent stopped in seconds
+    """
+
+    total_stop_time = int(np.sum(stop_durations))
+
+    if hold_ranges is not None:
+        total_stop_time += sum(end - start for start, end in merge_hold_ranges(hold_ranges))
+
+    return total_stop_time
+
+
+def validate_speed_profile(speed_kmh, max_speed, max_speed_change):
+    """
+    Finds the first point where a speed array breaks the car's constraints, so that infeasible
+        candidate speed profiles can be discarded before running the physics.
+
+    :param speed_kmh: (float[N]) speed of the car at each second in km/h
+    :param max_speed: (float) highest speed the car may drive in km/h
+    :param max_speed_change: (float) largest change in speed allowed between consecutive seconds in km/h
+
+    :returns: a tuple of the index of the first violating second and the type of violation,
+        either "speed" or "acceleration", or (-1, None) if the speed array is feasible
+    """
+
+    speed_kmh = np.asarray(speed_kmh, dtype=float)
+
+    over_speed = speed_kmh > max_speed
+
+    # a jump is attributed to the second that the car arrives at the new speed
+    over_acceleration = np.zeros(len(speed_kmh), dtype=bool)
+    over_acceleration[1:] = np.abs(np.diff(speed_kmh)) > max_speed_change
+
+    violations = np.flatnonzero(over_speed | over_acceleration)
+
+    if violations.size == 0:
+        return -1, None
+
+    first_violation = int(violations[0])
+
+    return first_violation, "speed" if over_speed[first_violation] else "acceleration"
+
+
+def find_stranded_index(state_of_charge, speed_kmh):
+    """
+    Finds the first time step where the car is asked to drive with a flat battery. Such a speed
+        profile is physically impossible, unlike one that is merely slow.
+
+    :param state_of_charge: (float[N]) battery state of charge at each time step (0.00 - 1.00)
+    :param speed_kmh: (float[N]) speed of the car at each time step in km/h
+
+    :returns: (int) index of the first time step where the state of charge is 0 or less while the
+        speed is positive, or -1 if there is none
+    """
+
+    stranded_indices = np.flatnonzero((np.asarray(state_of_charge) <= 0) & (np.asarray(speed_kmh) > 0))
+
+    return int(stranded_indices[0]) if stranded_indices.size != 0 else -1
+
+
+def get_nonzero_bounds(speed_kmh):
+    """
+    Finds the window of active driving in a speed array, so that leading and trailing zero speed
+        padding (from overnight holds or stops) can be trimmed.
+
+    :param speed_kmh: (float[N]) speed of the car at each time step in km/h
+
+    :returns: a tuple of the indices of the first and last nonzero speeds, or (-1, -1) if the car never moves
+    """
+
+    nonzero_indices = np.flatnonzero(speed_kmh)
+
+    if nonzero_indices.size == 0:
+        return -1, -1
+
+    return int(nonzero_indices[0]), int(nonzero_indices[-1])
+
+
+def calculate_speed_derivatives(speed_kmh, tick=1):
+    """
+    Calculates the acceleration and jerk of the car from a speed array, for comfort and motor stress
+        analysis. Central differences are used inside the array, and second-order one-sided differences
+        at its ends.
+
+    :param speed_kmh: (float[N]) speed of the car at each time step in km/h, with N >= 3
+    :param tick: (float) length of a time step in seconds
+
+    :returns: a tuple of the (float[N]) acceleration in m/s^2 and the (float[N]) jerk in m/s^3
+        at each time step
+    """
+
+    speed_ms = np.asarray(speed_kmh, dtype=float) / 3.6
+
+    acceleration = np.gradient(speed_ms, tick, edge_order=2)
+    jerk = np.gradient(acceleration, tick, edge_order=2)
+
+    return acceleration, jerk
+
+
+def calculate_moving_average(input_array, window_size):
+    """
+    Smooths an array, such as a spiky speed array from the optimizer, with a centred moving average.
+        Near the ends of the array the window shrinks so that only existing elements are averaged.
+        For even window sizes, the window reaches one element further after the centre than before it.
+
+    :param input_array: (float[N]) array to be smoothed
+    :param window_size: (int) number of elements averaged into each output element, at least 1
+
+    :returns: (float[N]) the smoothed array
+    """
+
+    cumulative_sum = np.insert(np.cumsum(input_array, dtype=float), 0, 0)
+
+    indices = np.arange(len(input_array))
+    window_starts = np.maximum(indices - (window_size - 1) // 2, 0)
+    window_ends = np.minimum(indices + window_size // 2 + 1, len(input_array))
+
+    return (cumulative_sum[window_ends] - cumulative_sum[window_starts]) / (window_ends - window_starts)
+
+
+def enforce_monotonic(cumulative_distances):
+    """
+    Clamps every element of an array of cumulative distances to be at least the element before it.
+        Floating point accumulation can occasionally produce a slightly decreasing cumulative
+        distance, which breaks the assumption made by calculate_closest_gis_indices and
+        calculate_closest_weather_indices that distances never decrease, so this can be run on
+        the distances before they are indexed.
+
+    :param cumulative_distances: (float[N]) array of cumulative distances
+
+    :returns: a tuple of the (float[N]) non-decreasing array and the number of elements that were corrected
+    """
+
+    monotonic_distances = np.maximum.accumulate(cumulative_distances)
+    corrections = np.count_nonzero(monotonic_distances != cumulative_distances)
+
+    return monotonic_distances, corrections
+
+
+def calculate_cumulative_sum(input_array, compensated=False):
+    """
+    Calculates the running sum of an array. With compensated=True, Kahan summation is used so that rounding
+        error does not build up over hundreds of thousands of terms, at the cost of a slower Python loop.
+
+    :param input_array: (float[N]) values to sum
+    :param compensated: (bool) if True, use Kahan (compensated) summation instead of np.cumsum
+
+    :returns: (float[N]) running sum of input_array
+    """
+
+    input_array = np.asarray(input_array, dtype=float)
+
+    if not compensated:
+        return np.cumsum(input_array)
+
+    cumulative_sum = np.empty_like(input_array)
+    total = 0.0
+    compensation = 0.0
+
+    for i, value in enumerate(input_array):
+        corrected_value = value - compensation
+        new_total = total + corrected_value
+
+        # recovers the low-order part of corrected_value lost when adding it to total
+        compensation = (new_total - total) - corrected_value
+        total = new_total
+
+        cumulative_sum[i] = total
+
+    return cumulative_sum
+
+
+def calculate_cumulative_distances(speed_kmh, route_length, tick=1, compensated=False):
+    """
+    Integrates a speed array into the distance the car has travelled by each time step, and finds the
+        first time step by which the car has covered the whole route.
+
+    :param speed_kmh: (float[N]) average speed of the car over each time step in km/h
+    :param route_length: (float) length of the route in metres
+    :param tick: (float or float[N]) length of each time step in seconds, either uniform or per time step
+    :param compensated: (bool) if True, use Kahan summation to reduce rounding drift over long routes
+
+    :returns: a tuple of the (float[N]) cumulative distance at each time step in metres, and the index
+        of the first time step where the cumulative distance reaches route_length, or -1 if it never does
+    """
+
+    cumulative_distances = calculate_cumulative_sum(speed_kmh * tick / 3.6, compensated=compensated)
+
+    completed_indices = np.flatnonzero(cumulative_distances >= route_length)
+    completion_index = int(completed_indices[0]) if completed_indices.size != 0 else -1
+
+    return cumulative_distances, completion_index
+
+
+def calculate_power_balance(array_powers, consumed_powers):
+    """
+    Compares the power the solar array produces against the power the car consumes, to show where
+        the car is drawing down its battery.
+
+    :param array_powers: (float[N]) power produced by the solar array at each time step in W
+    :param consumed_powers: (float[N]) power consumed by the car at each time step in W
+
+    :returns: a tuple of the (float[N]) net power at each time step in W, where > 0 means a surplus that
+        charges the battery, and a (bool[N]) array that is True wherever consumption exceeds generation
+    """
+
+    net_powers = np.asarray(array_powers, dtype=float) - np.asarray(consumed_powers, dtype=float)
+
+    return net_powers, net_powers < 0
+
+
+def calculate_cumulative_energy(net_powers, tick=1):
+    """
+    Integrates a net power array into the energy consumed and the energy regenerated over the race,
+        kept separate so that both can be reported.
+
+    :param net_powers: (float[N]) net power drawn from the battery at each time step in W,
+        where < 0 means power regenerated into the battery
+    :param tick: (float or float[N]) length of each time step in seconds, either uniform or per time step
+
+    :returns: a tuple of the (float[N]) cumulative energy consumed and the (float[N]) cumulative
+        energy regenerated by each time step, both positive and in Wh
+    """
+
+    energies = np.asarray(net_powers, dtype=float) * tick / 3600
+
+    consumed_energy = np.cumsum(np.clip(energies, a_min=0, a_max=None))
+    regenerated_energy = np.cumsum(-np.clip(energies, a_min=None, a_max=0))
+
+    return consumed_energy, regenerated_energy
+
+
+def calculate_required_speeds(cumulative_distances, route_length, remaining_times):
+    """
+    Calculates the average speed the car needs from each time step onwards to cover the rest of the
+        route within the time remaining, for a live race strategy display.
+
+    :param cumulative_distances: (float[N]) distance the car has travelled by each time step in metres
+    :param route_length: (float) length of the route in metres
+    :param remaining_times: (float[N]) time left to finish the route at each time step in seconds
+
+    :returns: (float[N]) required average speed at each time step in km/h. This is 0 once the route
+        is complete, and np.inf where distance remains but no time does
+    """
+
+    remaining_distances = np.clip(route_length - np.asarray(cumulative_distances, dtype=float), a_min=0, a_max=None)
+    remaining_times = np.asarray(remaining_times, dtype=float)
+
+    with np.errstate(divide="ignore", invalid="ignore"):
+        required_speeds = remaining_distances / remaining_times * 3.6
+
+    required_speeds[remaining_times <= 0] = np.inf
+    required_speeds[remaining_distances == 0] = 0
+
+    return required_speeds
+
+
+def calculate_solar_energy_by_period(array_powers, solar_times, tick=1):
+    """
+    Splits the energy collected by the solar array into what was collected in the morning and in the
+        afternoon, to help decide when the car should be stopped to charge.
+
+    :param array_powers: (float[N]) power produced by the solar array at each time step in W
+    :param solar_times: (float[N]) apparent solar time at each time step in hours from midnight
+    :param tick: (float or float[N]) length of each time step in seconds, either uniform or per time step
+
+    :returns: a tuple of the energy collected in the morning (solar time before 12), in the
+        afternoon (solar time from 12) and in total, all in J
+    """
+
+    energies = np.asarray(array_powers, dtype=float) * tick
+    morning = np.mod(solar_times, 24) < 12
+
+    morning_energy = np.sum(energies[morning])
+    afternoon_energy = np.sum(energies[~morning])
+
+    return morning_energy, afternoon_energy, morning_energy + afternoon_energy
+
+
+def calculate_distances_to_next_checkpoint(cumulative_distances, checkpoint_distances):
+    """
+    Calculates how far the car is from the next checkpoint it has not yet passed at each time step,
+        for a live race strategy display.
+
+    :param cumulative_distances: (float[N]) distance the car has travelled by each time step in metres
+    :param checkpoint_distances: (float[M]) increasing distances of the checkpoints from the start in metres
+
+    :returns: (float[N]) distance to the next checkpoint at each time step in metres. This reaches 0 when
+        the car arrives at a checkpoint, and is -1 once the car has passed the last checkpoint
+    """
+
+    checkpoint_distances = np.asarray(checkpoint_distances, dtype=float)
+    cumulative_distances = np.asarray(cumulative_distances, dtype=float)
+
+    next_checkpoints = np.searchsorted(checkpoint_distances, cumulative_distances, side="left")
+    passed_all = next_checkpoints == len(checkpoint_distances)
+
+    remaining_distances = checkpoint_distances[np.minimum(next_checkpoints, len(checkpoint_distances) - 1)] - \
+        cumulative_distances
+
+    return np.where(passed_all, -1, remaining_distances)
+
+
+def calculate_completed_laps(speed_kmh, track_length, tick=1):
+    """
+    Calculates how far the car gets around a looped track over the simulation. For FSGP the
+        objective is the distance driven within a fixed duration rather than the time taken
+        to finish a route, so this gives the FSGP optimizer its objective value directly.
+
+    :param speed_kmh: (float[N]) average speed of the car over each time step in km/h
+    :param track_length: (float) length of a single lap of the track in metres
+    :param tick: (float or float[N]) length of each time step in seconds, either uniform or per time step
+
+    :returns: a tuple of the total distance travelled in metres, the number of completed laps,
+        and the distance travelled into the final (partial) lap in metres
+    """
+
+    total_distance = np.sum(speed_kmh * tick / 3.6)
+    completed_laps, final_lap_distance = divmod(total_distance, track_length)
+
+    return total_distance, int(completed_laps), final_lap_distance
+
+
+def aggregate_by_segments(input_array, checkpoint_indices, mode="sum"):
+    """
+    Summarises a per-time-step array (such as energy used, distance or solar input) over each segment of
+        the race between consecutive checkpoints, for post-race analysis.
+
+    :param input_array: (float[N]) value at each time step
+    :param checkpoint_indices: (int[M]) increasing time step indices at which each checkpoint is reached
+    :param mode: (string) "sum" to total each segment, or "mean" to average it
+
+    :returns: (float[M+1]) summary of each segment: from the start of the race to the first checkpoint,
+        between each pair of consecutive checkpoints, and from the last checkpoint to the end of the race.
+        The mean of an empty segment is NaN
+    """
+
+    input_array = np.asarray(input_array, dtype=float)
+    boundaries = np.concatenate([[0], np.asarray(checkpoint_indices, dtype=int), [len(input_array)]])
+
+    cumulative_sum = np.insert(np.cumsum(input_array), 0, 0)
+    segment_sums = cumulative_sum[boundaries[1:]] - cumulative_sum[boundaries[:-1]]
+
+    if mode == "mean":
+        segment_lengths = np.diff(boundaries)
+        return np.divide(segment_sums, segment_lengths, out=np.full(len(segment_sums), np.nan),
+                         where=segment_lengths != 0)
+
+    return segment_sums
+
+
+def calculate_statistics(input_array, percentiles=(5, 25, 50, 75, 95)):
+    """
+    Summarises a result array (such as GHI or state of charge over the race) with a few statistics,
+        so that the full array does not need to be kept for reporting.
+
+    :param input_array: (float[N]) values to summarise
+    :param percentiles: (tuple) percentiles to report, between 0 and 100
+
+    :returns: a dict of the "min", "max", "mean" and "std" of input_array, and each percentile p as "p<p>"
+    """
+
+    input_array = np.asarray(input_array, dtype=float)
+
+    statistics = {
+        "min": np.min(input_array),
+        "max": np.max(input_array),
+        "mean": np.mean(input_array),
+        "std": np.std(input_array),
+    }
+
+    for percentile, value in zip(percentiles, np.percentile(input_array, percentiles)):
+        statistics[f"p{percentile}"] = value
+
+    return statistics
+
+
+def calculate_time_above_speed(speed_kmh, checkpoint_indices, speed_threshold, tick=1):
+    """
+    Calculates how long the car spends above a speed in each segment of the race between consecutive
+        checkpoints, for tire and motor thermal budgeting.
+
+    :param speed_kmh: (float[N]) speed of the car at each time step in km/h
+    :param checkpoint_indices: (int[M]) increasing time step indices at which each checkpoint is reached
+    :param speed_threshold: (float) speed above which time is counted in km/h
+    :param tick: (float or float[N]) length of each time step in seconds, either uniform or per time step
+
+    :returns: (float[M+1]) time spent above speed_threshold in each segment in seconds, with segments
+        split as in aggregate_by_segments
+    """
+
+    time_above = (np.asarray(speed_kmh) > speed_threshold) * np.broadcast_to(tick, np.shape(speed_kmh))
+
+    return aggregate_by_segments(time_above, checkpoint_indices)
+
+
+def calculate_lap_times(cumulative_distances, track_length, tick=1):
+    """
+    Finds when the car completes each lap of a looped track, and how long each lap took, to analyse
+        the pacing of an FSGP race.
+
+    :param cumulative_distances: (float[N]) distance the car has travelled by the end of each time step
+        in metres, as returned by calculate_cumulative_distances
+    :param track_length: (float) length of a single lap of the track in metres
+    :param tick: (float) length of a time step in seconds
+
+    :returns: a tuple of the (int[L]) time step index at which each completed lap finishes, and the
+        (float[L]) duration of each completed lap in seconds. A partial final lap is not included
+    """
+
+    cumulative_distances = np.asarray(cumulative_distances, dtype=float)
+
+    completed_laps = int(cumulative_distances[-1] // track_length) if len(cumulative_distances) != 0 else 0
+    lap_distances = track_length * np.arange(1, completed_laps + 1)
+
+    completion_indices = np.searchsorted(cumulative_distances, lap_distances, side="left")
+
+    # the first lap starts at the beginning of the first time step
+    lap_durations = np.diff(np.insert(completion_indices, 0, -1)) * tick
+
+    return completion_indices, lap_durations.astype(float)
+
+
+def decimate(input_array, output_length, mode="nearest"):
+    """
+    Reduces a large result array (such as a whole race at one second resolution) to a preview that is small
+        enough to plot. The array is split into output_length buckets of (nearly) equal size, and each bucket
+        is reduced according to the mode.
+
+    :param input_array: (float[N]) array to reduce
+    :param output_length: (int) number of buckets to reduce the array to
+    :param mode: (string) "nearest" to take the middle value of each bucket, "mean" to average each bucket,
+        or "minmax" to keep the minimum and maximum of each bucket for envelope plots, so spikes are not lost
+
+    :returns: (float[output_length]) the reduced array, or (float[2 * output_length]) alternating bucket
+        minimums and maximums for "minmax". If input_array already has at most output_length values it is
+        returned unchanged
+    """
+
+    input_array = np.asarray(input_array, dtype=float)
+
+    if len(input_array) <= output_length:
+        return input_array.copy()
+
+    bucket_starts = np.arange(output_length) * len(input_array) // output_length
+
+    if mode == "mean":
+        bucket_lengths = np.diff(np.append(bucket_starts, len(input_array)))
+        return np.add.reduceat(input_array, bucket_starts) / bucket_lengths
+
+    if mode == "minmax":
+        envelope = np.empty(2 * output_length)
+        envelope[0::2] = np.minimum.reduceat(input_array, bucket_starts)
+        envelope[1::2] = np.maximum.reduceat(input_array, bucket_starts)
+        return envelope
+
+    bucket_ends = np.append(bucket_starts[1:], len(input_array))
+    return input_array[(bucket_starts + bucket_ends - 1) // 2]
+
+
+def check_arrival_windows(arrival_times, earliest_arrival_times, latest_arrival_times):
+    """
+    Checks the times at which the car arrives at each checkpoint against the window in which it
+        is allowed to arrive there, so that the optimizer can penalise infeasible speed arrays.
+
+    :param arrival_times: (float[N]) times at which the car arrives at each checkpoint in seconds
+    :param earliest_arrival_times: (float[N]) times before which the car must not arrive in seconds
+    :param latest_arrival_times: (float[N]) times by which the car must have arrived in seconds
+
+    :returns: (int[N]) -1 where the car arrives too early, 1 where it arrives too late and 0 where
+        it arrives within the window
+    """
+
+    arrival_times = np.asarray(arrival_times)
+
+    return np.where(arrival_times < earliest_arrival_times, -1,
+                    np.where(arrival_times > latest_arrival_times, 1, 0))
+
+
 def hour_from_unix_timestamp(unix_timestamp):
     val = datetime.utcfromtimestamp(unix_timestamp)
     return val.hour
@@ -147,6 +723,305 @@ def calculate_path_distances(coords):
 
     return path_distances
 
+
+def calculate_haversine_distances(coords_1, coords_2, earth_radius=constants.EARTH_RADIUS, ellipsoidal=False):
+    """
+    Calculates the great-circle distances between pairs of coordinates using the
+    haversine formula. Unlike calculate_path_distances, this does not assume that the
+    coordinates are close together.
+    https://www.movable-type.co.uk/scripts/latlong.html
+
+    :param coords_1: A NumPy array [n][latitude, longitude] of starting coordinates
+    :param coords_2: A NumPy array [n][latitude, longitude] of ending coordinates
+    :param earth_radius: (float) radius of the sphere used to model the Earth, in metres
+    :param ellipsoidal: (bool) if True, ignore earth_radius and instead use the radius of curvature of the
+        WGS84 ellipsoid at the middle of each pair, in the direction of travel
+
+    :returns distances: a NumPy array [n][distances] in metres
+
+    Note:
+        - a sphere can be off by over 0.5% over long north-south stages, while the ellipsoidal
+          radius brings this down to a few metres per degree at roughly twice the cost. Vincenty's
+          formulae are more accurate still but iterative, and are not needed at route scales
+    """
+
+    coords_1 = np.radians(coords_1)
+    coords_2 = np.radians(coords_2)
+
+    diff = coords_2 - coords_1
+
+    a = np.square(np.sin(diff[..., 0] / 2)) + \
+        np.cos(coords_1[..., 0]) * np.cos(coords_2[..., 0]) * np.square(np.sin(diff[..., 1] / 2))
+
+    if ellipsoidal:
+        semi_major_axis = constants.WGS84_SEMI_MAJOR_AXIS
+        eccentricity_squared = constants.WGS84_ECCENTRICITY_SQUARED
+
+        mid_latitudes = (coords_1[..., 0] + coords_2[..., 0]) / 2
+        curvature_term = 1 - eccentricity_squared * np.square(np.sin(mid_latitudes))
+
+        # meridional and prime vertical radii of curvature
+        meridional_radii = semi_major_axis * (1 - eccentricity_squared) / curvature_term ** 1.5
+        prime_vertical_radii = semi_major_axis / np.sqrt(curvature_term)
+
+        bearings = np.arctan2(np.sin(diff[..., 1]) * np.cos(coords_2[..., 0]),
+                              np.cos(coords_1[..., 0]) * np.sin(coords_2[..., 0]) -
+                              np.sin(coords_1[..., 0]) * np.cos(coords_2[..., 0]) * np.cos(diff[..., 1]))
+
+        # Euler's formula for the radius of curvature along the bearing of travel
+        earth_radius = meridional_radii * prime_vertical_radii / \
+            (meridional_radii * np.square(np.sin(bearings)) + prime_vertical_radii * np.square(np.cos(bearings)))
+
+    # rounding can push a slightly above 1 for antipodal points
+    return 2 * earth_radius * np.arcsin(np.sqrt(np.clip(a, 0, 1)))
+
+
+def calculate_haversine_path_distances(coords, earth_radius=constants.EARTH_RADIUS, ellipsoidal=False):
+    """
+    Calculates the great-circle distance between every pair of adjacent coordinates.
+    This produces the same path_distances array as calculate_path_distances, without
+    the flat-Earth approximation.
+
+    :param coords: A NumPy array [n][latitude, longitude]
+    :param earth_radius: (float) radius of the sphere used to model the Earth, in metres
+    :param ellipsoidal: (bool) if True, model the Earth as the WGS84 ellipsoid instead of a sphere
+
+    :returns path_distances: a NumPy array [n-1][distances] in metres
+    """
+
+    return calculate_haversine_distances(coords[:-1], coords[1:], earth_radius, ellipsoidal)
+
+
+def snap_to_route(coords, route_coords, earth_radius=constants.EARTH_RADIUS):
+    """
+    Snaps GPS fixes, which drift off the planned route, to the closest coordinate of the route.
+
+    :param coords: A NumPy array [n][latitude, longitude] of measured GPS fixes
+    :param route_coords: A NumPy array [m][latitude, longitude] of the route's coordinates
+    :param earth_radius: (float) radius of the sphere used to model the Earth, in metres
+
+    :returns: a tuple of NumPy arrays [n][route indices] of the closest route coordinate to each
+        fix, and [n][distances] from each fix to that route coordinate in metres
+
+    Note:
+        - every fix is compared against every route coordinate, which is O(n*m). A spatial index
+          can replace the candidate search below without changing the rest of the function
+    """
+
+    coords = np.asarray(coords, dtype=float)
+    route_coords = np.asarray(route_coords, dtype=float)
+
+    route_indices = np.empty(len(coords), dtype=int)
+    distances = np.empty(len(coords))
+
+    for i, coord in enumerate(coords):
+        candidate_indices = np.arange(len(route_coords))
+
+        candidate_distances = calculate_haversine_distances(coord, route_coords[candidate_indices], earth_radius)
+        closest = np.argmin(candidate_distances)
+
+        route_indices[i] = candidate_indices[closest]
+        distances[i] = candidate_distances[closest]
+
+    return route_indices, distances
+
+
+def calculate_bearings(coords_1, coords_2):
+    """
+    Calculates the forward azimuth (initial great-circle bearing) from each coordinate
+    in coords_1 to the corresponding coordinate in coords_2.
+    https://www.movable-type.co.uk/scripts/latlong.html
+
+    :param coords_1: A NumPy array [n][latitude, longitude] of starting coordinates
+    :param coords_2: A NumPy array [n][latitude, longitude] of ending coordinates
+
+    :returns bearings: a NumPy array [n][bearings], in degrees clockwise from north in the range [0, 360)
+    """
+
+    coords_1 = np.radians(coords_1)
+    coords_2 = np.radians(coords_2)
+
+    lat_1 = coords_1[..., 0]
+    lat_2 = coords_2[..., 0]
+    diff_lng = coords_2[..., 1] - coords_1[..., 1]
+
+    y = np.sin(diff_lng) * np.cos(lat_2)
+    x = np.cos(lat_1) * np.sin(lat_2) - np.sin(lat_1) * np.cos(lat_2) * np.cos(diff_lng)
+
+    return (np.degrees(np.arctan2(y, x)) + 360) % 360
+
+
+def calculate_path_bearings(coords):
+    """
+    Calculates the forward azimuth (initial great-circle bearing) from every coordinate
+    to the coordinate after it. The last coordinate has no coordinate after it, so it
+    repeats the bearing of the second-last coordinate.
+
+    :param coords: A NumPy array [n][latitude, longitude]
+
+    :returns bearings: a NumPy array [n][bearings], in degrees clockwise from north in the range [0, 360)
+    """
+
+    bearings = calculate_bearings(coords[:-1], coords[1:])
+
+    return np.append(bearings, bearings[-1])
+
+
+def calculate_path_curvatures(coords, max_lateral_acceleration, earth_radius=constants.EARTH_RADIUS):
+    """
+    Calculates the curvature of the road at every coordinate from the circle passing through it and
+    its two neighbouring coordinates, and the fastest the car can take each corner without exceeding
+    a lateral acceleration.
+
+    :param coords: A NumPy array [n][latitude, longitude]
+    :param max_lateral_acceleration: (float) highest sideways acceleration the car may have in m/s^2
+    :param earth_radius: (float) radius of the sphere used to model the Earth, in metres
+
+    :returns: a tuple of NumPy arrays [n][curvatures] in 1/m, and [n][speeds] of the highest safe
+        cornering speed in km/h. The first and last coordinates, and coordinates in line with
+        their neighbours, have a curvature of 0 and an unlimited (np.inf) cornering speed
+    """
+
+    coords_radians = np.radians(coords)
+
+    previous_coords = coords_radians[:-2]
+    current_coords = coords_radians[1:-1]
+    next_coords = coords_radians[2:]
+
+    # positions of the neighbouring coordinates in metres, on a plane centred on each coordinate
+    cos_latitudes = np.cos(current_coords[:, 0])
+    previous_x = earth_radius * (previous_coords[:, 1] - current_coords[:, 1]) * cos_latitudes
+    previous_y = earth_radius * (previous_coords[:, 0] - current_coords[:, 0])
+    next_x = earth_radius * (next_coords[:, 1] - current_coords[:, 1]) * cos_latitudes
+    next_y = earth_radius * (next_coords[:, 0] - current_coords[:, 0])
+
+    side_lengths = np.hypot(previous_x, previous_y) * np.hypot(next_x, next_y) * \
+        np.hypot(next_x - previous_x, next_y - previous_y)
+    twice_triangle_areas = np.abs(previous_x * next_y - previous_y * next_x)
+
+    # Menger curvature, the inverse of the radius of the circle through the three coordinates
+    curvatures = np.divide(2 * twice_triangle_areas, side_lengths, out=np.zeros_like(side_lengths),
+                           where=side_lengths != 0)
+    curvatures = np.pad(curvatures, 1)
+
+    max_speeds = np.full_like(curvatures, np.inf)
+    cornering = curvatures > 0
+    max_speeds[cornering] = np.sqrt(max_lateral_acceleration / curvatures[cornering]) * 3.6
+
+    return curvatures, max_speeds
+
+
+def calculate_cross_track_errors(coords, route_coords, earth_radius=constants.EARTH_RADIUS):
+    """
+    Calculates how far each GPS fix is from the route, measured to the closest point on any
+    segment of the route rather than to the closest route coordinate (see snap_to_route).
+    https://www.movable-type.co.uk/scripts/latlong.html
+
+    :param coords: A NumPy array [n][latitude, longitude] of measured GPS fixes
+    :param route_coords: A NumPy array [m][latitude, longitude] of the route's coordinates
+    :param earth_radius: (float) radius of the sphere used to model the Earth, in metres
+
+    :returns cross_track_errors: a NumPy array [n][distances] from each fix to the route in metres
+
+    Note:
+        - like snap_to_route, every fix is compared against every route segment, which is O(n*m)
+    """
+
+    coords = np.asarray(coords, dtype=float)
+    route_coords = np.asarray(route_coords, dtype=float)
+
+    segment_starts = route_coords[:-1]
+    segment_ends = route_coords[1:]
+
+    # angular lengths and bearings of the route segments
+    segment_lengths = calculate_haversine_distances(segment_starts, segment_ends, earth_radius) / earth_radius
+    segment_bearings = np.radians(calculate_bearings(segment_starts, segment_ends))
+
+    cross_track_errors = np.empty(len(coords))
+
+    for i, coord in enumerate(coords):
+        start_distances = calculate_haversine_distances(segment_starts, coord, earth_radius) / earth_radius
+        end_distances = calculate_haversine_distances(segment_ends, coord, earth_radius) / earth_radius
+        bearing_differences = np.radians(calculate_bearings(segment_starts, coord)) - segment_bearings
+
+        # angular distance from the fix to the great circle through each segment, and how far along it the fix is
+        cross_track_distances = np.arcsin(np.clip(np.sin(start_distances) * np.sin(bearing_differences), -1, 1))
+        along_track_distances = np.arccos(np.clip(np.cos(start_distances) / np.cos(cross_track_distances), -1, 1))
+
+        # the closest point of a segment is one of its ends when the fix is before or beyond it
+        segment_distances = np.abs(cross_track_distances)
+        before_start = np.cos(bearing_differences) < 0
+        beyond_end = along_track_distances > segment_lengths
+
+        segment_distances = np.where(before_start, start_distances, segment_distances)
+        segment_distances = np.where(~before_start & beyond_end, end_distances, segment_distances)
+
+        cross_track_errors[i] = np.min(segment_distances) * earth_radius
+
+    return cross_track_errors
+
+
+def interpolate_path_coordinates(coords, gis_indices, weights):
+    """
+    Calculates the exact coordinate that the car occupies at each time step by spherical linear
+        interpolation (slerp) along the great circle between the path coordinate the car has most
+        recently passed and the path coordinate after it.
+    https://en.wikipedia.org/wiki/Slerp
+
+    :param coords: A NumPy array [n][latitude, longitude] of path coordinates
+    :param gis_indices: (int[N]) index of the path coordinate most recently passed at each time step
+    :param weights: (float[N]) fraction of the way from coords[gis_indices] to the next path coordinate,
+        between 0 and 1
+
+    :returns: A NumPy array [N][latitude, longitude] of the interpolated coordinates
+    """
+
+    gis_indices = np.asarray(gis_indices)
+    weights = np.asarray(weights, dtype=float)[..., np.newaxis]
+
+    next_indices = np.minimum(gis_indices + 1, len(coords) - 1)
+
+    # convert the coordinates into unit vectors from the centre of the Earth
+    coords_radians = np.radians(coords)
+    vectors = np.stack((np.cos(coords_radians[:, 0]) * np.cos(coords_radians[:, 1]),
+                        np.cos(coords_radians[:, 0]) * np.sin(coords_radians[:, 1]),
+                        np.sin(coords_radians[:, 0])), axis=-1)
+
+    start_vectors = vectors[gis_indices]
+    end_vectors = vectors[next_indices]
+
+    # angle subtended by each segment at the centre of the Earth
+    omega = np.arccos(np.clip(np.sum(start_vectors * end_vectors, axis=-1), -1, 1))[..., np.newaxis]
+    sin_omega = np.sin(omega)
+
+    # segments of (nearly) zero length fall back to linear interpolation to avoid dividing by zero
+    with np.errstate(divide="ignore", invalid="ignore"):
+        start_weights = np.where(sin_omega > 1e-12, np.sin((1 - weights) * omega) / sin_omega, 1 - weights)
+        end_weights = np.where(sin_omega > 1e-12, np.sin(weights * omega) / sin_omega, weights)
+
+    interpolated_vectors = start_weights * start_vectors + end_weights * end_vectors
+
+    latitudes = np.arctan2(interpolated_vectors[..., 2], np.hypot(interpolated_vectors[..., 0],
+                                                                  interpolated_vectors[..., 1]))
+    longitudes = np.arctan2(interpolated_vectors[..., 1], interpolated_vectors[..., 0])
+
+    return np.degrees(np.stack((latitudes, longitudes), axis=-1))
+
+
+def calculate_angular_difference(angle_1, angle_2):
+    """
+    Returns the signed difference (angle_1 - angle_2) between two angles, wrapped into
+        the range [-180, 180) so that e.g. 10 and 350 degrees are 20 degrees apart
+
+    angle_1: (float[N]) The angles to subtract from, in degrees
+    angle_2: (float[N]) The angles to subtract, in degrees
+
+    Returns: (float[N]) The wrapped differences between the angles, in degrees
+    """
+
+    return (np.asarray(angle_1) - angle_2 + 180) % 360 - 180
+
+
 def get_array_directional_wind_speed(vehicle_bearings, wind_speeds, wind_directions):
     """
     Returns the array of wind speed in m/s, in the direction opposite to the
@@ -157,13 +1032,51 @@ def get_array_directional_wind_speed(vehicle_bearings, wind_speeds, wind_directi
     wind_directions: (float[N]) The wind direction in the meteorlogical convention. To convert from
         meteorlogical convention to azimuth angle, use (x + 180) % 360
 
-    Returns: The wind speeds in the direction opposite to the bearing of the vehicle
+    note: The inputs can equally be per route coordinate rather than per time step. Passing the
+        bearings from calculate_path_bearings with the forecast wind at each coordinate gives the
+        headwind profile along the route, to find its most exposed stretches
+
+    Returns: The wind speeds in the direction opposite to the bearing of the vehicle, where > 0
+        means a headwind and < 0 means a tailwind
     """
 
     # wind direction is 90 degrees meteorlogical, so it is 270 degrees azimuthal. car is 90 degrees
     #   cos(90 - 90) = cos(0) = 1. Wind speed is moving opposite to the car,
     # car is 270 degrees, cos(90-270) = -1. Wind speed is in direction of the car.
-    return wind_speeds * (np.cos(np.radians(wind_directions - vehicle_bearings)))
+    return wind_speeds * (np.cos(np.radians(calculate_angular_difference(wind_directions, vehicle_bearings))))
+
+
+def calculate_airspeed(speed_kmh, headwind_speeds):
+    """
+    Returns the speed of the air relative to the vehicle along its heading, from its ground speed and
+        the wind speed against its direction of travel
+
+    speed_kmh: (float[N]) The ground speed of the vehicle in km/h
+    headwind_speeds: (float[N]) The wind speeds in m/s, where > 0 means against the direction of the vehicle
+
+    Returns: The airspeeds in m/s
+    """
+
+    return speed_kmh / 3.6 + headwind_speeds
+
+
+def calculate_effective_airspeed(speed_kmh, vehicle_bearings, wind_speeds, wind_directions):
+    """
+    Returns the array of effective airspeed, the speed of the air relative to the vehicle along its
+        heading. This is the speed that drag and convective cooling of the array depend on.
+
+    speed_kmh: (float[N]) The ground speed of the vehicle in km/h
+    vehicle_bearings: (float[N]) The azimuth angles that the vehicle in, in degrees
+    wind_speeds: (float[N]) The absolute speeds in m/s
+    wind_directions: (float[N]) The wind direction in the meteorlogical convention
+
+    Returns: The effective airspeeds in m/s, greater than the ground speed in a headwind and
+        less than it in a tailwind
+    """
+
+    headwind_speeds = get_array_directional_wind_speed(vehicle_bearings, wind_speeds, wind_directions)
+
+    return calculate_airspeed(speed_kmh, headwind_speeds)
 
 
 def get_day_of_year(day, month, year):
@@ -196,6 +1109,21 @@ def calculate_declination_angle(day_of_year):
     return declination_angle
 
 
+def calculate_extraterrestrial_irradiance(day_of_year):
+    """
+    Calculates the irradiance of sunlight at the top of the atmosphere, which varies over
+    the year by about 3.3% as the distance between the Earth and the Sun changes
+    https://www.pveducation.org/pvcdrom/properties-of-sunlight/solar-radiation-outside-the-earths-atmosphere
+
+    day_of_year: The number of the day of the current year, with January 1
+        being the first day of the year.
+
+    Returns: The extraterrestrial irradiance normal to the Sun, in W/m2
+    """
+
+    return constants.SOLAR_CONSTANT * (1 + 0.033 * np.cos(2 * np.pi * np.float_(day_of_year) / 365))
+
+
 # ----- Calculation of Apparent Solar Time -----
 # @jit
 def calculate_eot_correction(day_of_year):
@@ -252,10 +1180,48 @@ def local_time_to_apparent_solar_time(time_zone_utc, day_of_year, local_time,
     lstm = calculate_LSTM(time_zone_utc)
     eot = calculate_eot_correction(day_of_year)
 
-    # local solar time
-    lst = local_time + np.float_(longitude - lstm) / 15 + np.float_(eot) / 60
+    return calculate_apparent_solar_time(local_time, longitude, lstm, eot)
+
+
+def calculate_apparent_solar_time(local_time, longitude, lstm, eot):
+    """
+    Corrects the local time by the time correction factor, which accounts for the
+    longitude of a location within its time zone and for the equation of time, to
+    give the apparent solar time.
+    https://www.pveducation.org/pvcdrom/properties-of-sunlight/solar-time
+
+    local_time: The local time in hours from midnight (Adjust for Daylight Savings)
+    longitude: The longitude of a location on Earth, in degrees east
+    lstm: The Local Standard Time Meridian of the location's time zone, in degrees east
+    eot: The equation of time correction, in minutes
+
+    note: The Sun crosses 1 degree of longitude every 4 minutes, so a location east of
+            its standard meridian sees solar noon earlier than clock noon
+
+    Returns: The Apparent Solar Time of a location, in hours from midnight
+    """
+
+    # time correction factor, in minutes
+    time_correction = 4 * np.float_(longitude - lstm) + np.float_(eot)
+
+    return local_time + time_correction / 60
+
+
+def solar_time_to_hour_angle(solar_time):
+    """
+    Converts the apparent solar time to the hour angle of the Sun, which is 0 at solar noon,
+    negative in the morning and positive in the afternoon.
+    https://www.pveducation.org/pvcdrom/properties-of-sunlight/solar-time
+
+    solar_time: The apparent solar time in hours from midnight. This may run past 24 hours
+        for simulations that span multiple days
+
+    Returns: The Hour Angle in radians, wrapped to the range [-pi, pi)
+    """
 
-    return lst
+    hour_angle = 15 * (np.asarray(solar_time, dtype=float) - 12)
+
+    return np.radians((hour_angle + 180) % 360 - 180)
 
 
 def calculate_path_gradients(elevations, distances):
@@ -291,6 +1257,116 @@ def calculate_path_gradients(elevations, distances):
     return gradients
 
 
+def interpolate_elevations(sample_distances, sample_elevations, path_distances):
+    """
+    Linearly interpolates an elevation profile that is sampled more coarsely than the path
+    coordinates onto every path coordinate. Coordinates before the first or after the last
+    sample take the elevation of that sample.
+
+    :param sample_distances: [m][distances] increasing distances from the start of the route at which
+        the elevation is known, in metres
+    :param sample_elevations: [m][elevations] elevation at each of those distances
+    :param path_distances: [n-1][distances] distance between every pair of adjacent path coordinates,
+        as returned by calculate_path_distances
+
+    :returns: a NumPy array [n][elevations] of the elevation at every path coordinate
+    """
+
+    # distance of each path coordinate from the start of the route
+    path_positions = np.insert(np.cumsum(path_distances), 0, 0)
+
+    return np.interp(path_positions, sample_distances, sample_elevations)
+
+
+def smooth_path_gradients(gradients, distances, window_distance):
+    """
+    Smooths the road gradient of every path segment with a distance-weighted moving average, to remove
+    the staircase artifacts that digital elevation models leave in the gradient. The smoothed gradients
+    are offset so that the net elevation change along the path is unchanged.
+
+    :param gradients: [n-1][gradients] gradient of every path segment, as returned by calculate_path_gradients
+    :param distances: [n-1][distances] length of every path segment in metres
+    :param window_distance: (float) length of road that each gradient is averaged over in metres
+
+    :returns: [n-1][gradients] smoothed gradient of every path segment
+    """
+
+    gradients = np.asarray(gradients, dtype=float)
+    distances = np.asarray(distances, dtype=float)
+
+    # distance of the middle of each segment from the start of the path
+    segment_midpoints = np.cumsum(distances) - distances / 2
+
+    window_starts = np.searchsorted(segment_midpoints, segment_midpoints - window_distance / 2, side="left")
+    window_ends = np.searchsorted(segment_midpoints, segment_midpoints + window_distance / 2, side="right")
+
+    # elevation change and length of road covered by each window
+    cumulative_rises = np.insert(np.cumsum(gradients * distances), 0, 0)
+    cumulative_distances = np.insert(np.cumsum(distances), 0, 0)
+
+    window_rises = cumulative_rises[window_ends] - cumulative_rises[window_starts]
+    window_lengths = cumulative_distances[window_ends] - cumulative_distances[window_starts]
+
+    smoothed_gradients = np.divide(window_rises, window_lengths, out=gradients.copy(), where=window_lengths != 0)
+
+    # windows are cut short at the ends of the path, so restore the net elevation change
+    total_distance = np.sum(distances)
+    if total_distance != 0:
+        smoothed_gradients += (np.sum(gradients * distances) - np.sum(smoothed_gradients * distances)) / total_distance
+
+    return smoothed_gradients
+
+
+def gather_elevations_and_gradients(gis_indices, elevations, distances):
+    """
+    Gets the elevation and the road gradient experienced at each time step from the path
+    indices occupied at each time step.
+
+    :param gis_indices: (int[N]) index of the path coordinate occupied at each time step
+    :param elevations: [n][elevations] elevation of every path coordinate
+    :param distances: [n-1][distances] distance between every pair of adjacent path coordinates
+
+    :returns: a tuple of [N][elevations] and [N][gradients] at each time step
+
+    Note:
+        - the gradient at a coordinate is that of the segment leaving it, and the last
+          coordinate repeats the gradient of the segment arriving at it
+    """
+
+    path_gradients = calculate_path_gradients(elevations, distances)
+    path_gradients = np.append(path_gradients, path_gradients[-1])
+
+    return elevations[gis_indices], path_gradients[gis_indices]
+
+
+def generate_coordinate_cloud_phases(num_coordinates, seed):
+    """
+    Generates a repeatable pseudo-random cloud phase for every route coordinate, so that weather
+    ensembles can be reproduced exactly from their seed.
+
+    :param num_coordinates: (int) number of route coordinates
+    :param seed: (int) master seed of the ensemble member
+
+    :returns: a NumPy array [n][phases] in the range [0, 1)
+
+    Note:
+        - the phase of a coordinate is a splitmix64 hash of its index and the seed, so extending or
+          truncating the route does not change the phases of the other coordinates, and the phases
+          do not depend on the version of NumPy's random number generators
+    """
+
+    # the state that a splitmix64 generator seeded with the seed would have when generating each phase
+    indices = np.arange(1, num_coordinates + 1, dtype=np.uint64)
+    z = np.uint64(int(seed) & 0xFFFFFFFFFFFFFFFF) + indices * np.uint64(0x9E3779B97F4A7C15)
+
+    z = (z ^ (z >> np.uint64(30))) * np.uint64(0xBF58476D1CE4E5B9)
+    z = (z ^ (z >> np.uint64(27))) * np.uint64(0x94D049BB133111EB)
+    z = z ^ (z >> np.uint64(31))
+
+    # the top 53 bits of the hash are exactly representable as a double in [0, 1)
+    return (z >> np.uint64(11)).astype(np.float64) * 2.0 ** -53
+
+
 def cull_dataset(coords):
     """
     As we currently have a limited number of API calls(60) every minute with the
@@ -330,6 +1406,42 @@ def compute_elevation_angle_math(declination_angle, hour_angle, latitude):
     return np.degrees(elevation_angle)
 
 
+def calculate_solar_elevation(declination_angle, hour_angle, latitude):
+    """
+    Gets the elevation angle of the Sun and whether it is daylight, given the
+    declination angle, hour angle, and latitude. This is the single place that
+    decides when the Sun is below the horizon, for gating array power and shading.
+
+    declination_angle: The declination angle of the Earth relative to the Sun in degrees
+    hour_angle: The hour angle of the sun in the sky in degrees
+    latitude: The latitude of the vehicle in degrees
+
+    Returns: A tuple of the elevation angle (90 - zenith angle) in degrees and a boolean
+        mask that is True wherever the Sun is above the horizon
+    """
+
+    elevation_angle = compute_elevation_angle_math(declination_angle, hour_angle, latitude)
+
+    return elevation_angle, elevation_angle > 0
+
+
+def calculate_horizon_shading(elevation_angles, horizon_angles, gis_indices):
+    """
+    Gets the factor by which terrain shades the array from direct sunlight at each time step. In
+    mountainous stages the Sun can be above the horizon but still hidden behind the terrain
+    around the route.
+
+    elevation_angles: (float[N]) The elevation angle of the Sun at each time step in degrees
+    horizon_angles: (float[n]) The angle of the highest obstruction around each route coordinate,
+        in the direction of the Sun, in degrees
+    gis_indices: (int[N]) The index of the route coordinate occupied at each time step
+
+    Returns: (float[N]) 0 where the Sun is below the local horizon and 1 where it is visible
+    """
+
+    return (elevation_angles > horizon_angles[gis_indices]).astype(float)
+
+
 if __name__ == '__main__':
     # speed_array input
     speed_array = np.array([45, 87, 65, 89, 43, 54, 45, 23, 34, 20])
@@ -1,5 +1,4 @@
 import json
-import math
 import os
 import sys
 
@@ -89,7 +88,7 @@ class GIS:
         self.path_gradients = helpers.calculate_path_gradients(self.path_elevations,
                                                             self.path_distances)
 
-    def calculate_closest_gis_indices(self, cumulative_distances):
+    def calculate_closest_gis_indices(self, cumulative_distances, reverse=False):
         """
         Takes in an array of point distances from starting point, returns a list of 
         self.path indices of coordinates which have a distance from the starting point
@@ -97,18 +96,56 @@ class GIS:
 
         :param cumulative_distances: (float[N]) array of distances,
         where cumulative_distances[x] > cumulative_distances[x-1]
+        :param reverse: (bool) set to True when the route is driven backwards, from the last
+        coordinate of self.path to the first (e.g. the return leg of an out-and-back stage)
         
         :returns: (float[N]) array of indices of path
         """
 
-        current_coordinate_index = 0
+        closest_gis_indices, _ = self.calculate_closest_gis_indices_chunk(cumulative_distances, reverse=reverse)
+
+        return closest_gis_indices
+
+    def calculate_closest_gis_indices_chunk(self, cumulative_distances, current_coordinate_index=None,
+                                            reverse=False):
+        """
+        Does the same as calculate_closest_gis_indices, but for a single chunk of a longer array of point
+        distances. The path index that the chunk ended on is returned along with the indices, and passing it
+        back in for the next chunk gives the same result as processing the whole array at once.
+
+        :param cumulative_distances: (float[N]) chunk of an array of distances,
+        where cumulative_distances[x] > cumulative_distances[x-1]
+        :param current_coordinate_index: (int) path index that the previous chunk ended on, or None
+        for the first chunk
+        :param reverse: (bool) set to True when the route is driven backwards, from the last
+        coordinate of self.path to the first
+
+        :returns: a tuple of the (float[N]) array of indices of path and the path index this chunk ended on
+        """
+
+        helpers.check_finite(cumulative_distances, "cumulative_distances")
+        helpers.check_finite(self.path_distances, "path_distances")
+
         result = []
 
         path_distances = self.path_distances.copy()
+
+        # when driving in reverse, walk along the reversed path and mirror the indices afterwards
+        if reverse:
+            path_distances = path_distances[::-1]
+
         cumulative_path_distances = np.cumsum(path_distances)
         cumulative_path_distances[::2] *= -1
         average_distances = np.abs(np.diff(cumulative_path_distances) / 2)
 
+        # index of the last coordinate of self.path, which reverse indices are mirrored around
+        last_coordinate_index = len(self.path_distances)
+
+        if current_coordinate_index is None:
+            current_coordinate_index = 0
+        elif reverse:
+            current_coordinate_index = last_coordinate_index - current_coordinate_index
+
         with tqdm(total=len(cumulative_distances), file=sys.stdout, desc="Calculating closest GIS indices") as pbar:
             for distance in np.nditer(cumulative_distances):
                 if distance > average_distances[current_coordinate_index]:
@@ -125,7 +162,64 @@ class GIS:
 
         print()
 
-        return np.array(result)
+        if reverse:
+            return last_coordinate_index - np.array(result), last_coordinate_index - current_coordinate_index
+
+        return np.array(result), current_coordinate_index
+
+    def calculate_dwell_counts(self, gis_indices):
+        """
+        Takes in an array of self.path indices occupied at each time step (as returned by
+        calculate_closest_gis_indices), and returns how many time steps were spent at each path coordinate
+
+        :param gis_indices: (int[N]) array of indices of path
+
+        :returns: (int[n]) array of the number of time steps spent at every path coordinate
+        """
+
+        return np.bincount(gis_indices, minlength=len(self.path_distances) + 1)
+
+    def calculate_route_overshoot(self, cumulative_distances):
+        """
+        Takes in an array of point distances from starting point, returns which of those points lie
+        beyond the end of the route. calculate_closest_gis_indices silently clamps such points to the
+        last path coordinate, so this tells us that the car has finished and that the remaining
+        points are post-race.
+
+        :param cumulative_distances: (float[N]) array of distances from the starting point in metres
+
+        :returns: (bool[N]) array which is True wherever the distance exceeds the total route length
+        """
+
+        return np.asarray(cumulative_distances) > np.sum(self.path_distances)
+
+    def snap_distances_to_path_indices(self, distances):
+        """
+        Takes in an array of approximate distances from the starting point (such as those of waypoints),
+        and returns the index of the path coordinate closest to each of them. The snapped indices land
+        exactly on path coordinates, and are clamped to the range of indices that calculate_closest_gis_indices
+        returns, so they can be compared against gis_indices with equality.
+
+        :param distances: (float[N]) array of distances from the starting point in metres
+
+        :returns: (int[N]) array of the closest path coordinate indices, where ties go to the earlier coordinate
+        """
+
+        helpers.check_finite(distances, "distances")
+
+        # distance of each path coordinate from the starting point
+        path_positions = np.insert(np.cumsum(self.path_distances), 0, 0)
+
+        upper_indices = np.clip(np.searchsorted(path_positions, distances), 1, len(path_positions) - 1)
+        lower_indices = upper_indices - 1
+
+        closer_to_upper = np.abs(path_positions[upper_indices] - distances) < \
+            np.abs(distances - path_positions[lower_indices])
+
+        snapped_indices = np.where(closer_to_upper, upper_indices, lower_indices)
+
+        # calculate_closest_gis_indices never goes past the second last index of its average distances
+        return np.minimum(snapped_indices, len(self.path_distances) - 2)
 
     def calculate_time_zones(self, coords):
         """
@@ -339,28 +433,8 @@ class GIS:
         Calculates the bearing of the vehicle between consecutive points
         https://www.movable-type.co.uk/scripts/latlong.html
         """
-        bearing_array = np.zeros(len(self.path))
-
-        for index in range(0, len(self.path) - 1):
-            coord_1 = np.radians(self.path[index])
-            coord_2 = np.radians(self.path[index + 1])
-
-            y = math.sin(coord_2[1] - coord_1[1]) \
-                * math.cos(coord_2[0])
-
-            x = math.cos(coord_1[0]) \
-                * math.sin(coord_2[0]) \
-                - math.sin(coord_1[0]) \
-                * math.cos(coord_2[0]) \
-                * math.cos(coord_2[1] - coord_1[1])
-
-            theta = math.atan2(y, x)
-
-            bearing_array[index] = ((theta * 180) / math.pi + 360) % 360
-
-        bearing_array[-1] = bearing_array[-2]
 
-        return bearing_array
+        return helpers.calculate_path_bearings(self.path)
 
 
 if __name__ == "__main__":
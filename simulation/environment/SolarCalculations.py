@@ -8,6 +8,7 @@ A class to perform calculation and approximations for obtaining quantities
 
 import datetime
 import numpy as np
+from collections import OrderedDict
 from simulation.common import helpers
 from tqdm import tqdm
 import sys
@@ -23,6 +24,10 @@ class SolarCalculations:
         # Solar Constant in W/m2
         self.S_0 = 1353
 
+        # days of the year and local times decoded from unix timestamps, keyed by the caller's cache key
+        self.decoded_times_cache = OrderedDict()
+        self.decoded_times_cache_size = 8
+
     # ----- Calculation of solar position in the sky -----
 
     def calculate_hour_angle(self, time_zone_utc, day_of_year, local_time, longitude):
@@ -39,13 +44,13 @@ class SolarCalculations:
         note: If local time and time_zone_utc are both unadjusted for Daylight Savings, the 
                 calculation will end up just the same
 
-        Returns: The Hour Angle in degrees. 
+        Returns: The Hour Angle in degrees, in the range [-180, 180)
         """
 
         lst = helpers.local_time_to_apparent_solar_time(time_zone_utc / 3600, day_of_year,
                                                      local_time, longitude)
 
-        hour_angle = 15 * (lst - 12)
+        hour_angle = np.degrees(helpers.solar_time_to_hour_angle(lst))
 
         return hour_angle
 
@@ -258,10 +263,118 @@ class SolarCalculations:
 
         return GHI
 
+    def calculate_clear_sky_GHI(self, zenith_angle):
+        """
+        Calculates the Global Horizontal Irradiance under a cloudless sky using the
+        Haurwitz clear sky model, which only depends on the position of the Sun
+        https://pvlib-python.readthedocs.io/en/stable/reference/generated/pvlib.clearsky.haurwitz.html
+
+        zenith_angle: The zenith angle of the Sun in degrees
+
+        note: The GHI is 0 whenever the Sun is at or below the horizon
+
+        Returns: The clear sky Global Horizontal Irradiance in W/m2
+        """
+
+        cos_zenith = np.cos(np.radians(zenith_angle))
+
+        # avoid dividing by zero for the Sun on the horizon, where the GHI is 0 anyway
+        safe_cos_zenith = np.where(cos_zenith > 0, cos_zenith, 1)
+        GHI = 1098 * cos_zenith * np.exp(-0.059 / safe_cos_zenith)
+
+        return np.where(cos_zenith > 0, GHI, 0)
+
+    def calculate_cloud_attenuated_GHI(self, clear_sky_GHI, cloud_cover):
+        """
+        Calculates the Global Horizontal Irradiance that gets through cloud cover using the
+        Kasten-Czeplak (1980) transmittance, rather than reducing the GHI linearly with cloud cover
+
+        clear_sky_GHI: The Global Horizontal Irradiance under a cloudless sky in W/m2
+        cloud_cover: The fraction of the sky covered by cloud, from 0 to 1
+
+        note: Even under full cloud cover, a quarter of the clear sky GHI gets through
+
+        Returns: The Global Horizontal Irradiance under the cloud cover in W/m2
+        """
+
+        cloud_cover = np.clip(cloud_cover, a_min=0, a_max=1)
+
+        return clear_sky_GHI * (1 - 0.75 * np.power(cloud_cover, 3.4))
+
+    def calculate_plane_of_array_irradiance(self, DNI, DHI, zenith_angle, azimuth_angle,
+                                            array_tilt, array_azimuth, albedo=0.2):
+        """
+        Calculates the irradiance incident on a tilted solar array (the plane of array irradiance)
+        from the direct and diffuse components of sunlight, using the isotropic sky model
+        https://www.pveducation.org/pvcdrom/properties-of-sunlight/arbitrary-orientation-and-tilt
+
+        DNI: The Direct Normal Irradiance in W/m2
+        DHI: The Diffuse Horizontal Irradiance in W/m2
+        zenith_angle: The zenith angle of the Sun in degrees
+        azimuth_angle: The azimuth angle of the Sun in degrees, clockwise from north
+        array_tilt: The angle between the array and the horizontal in degrees, where 0 is a flat array
+        array_azimuth: The azimuth angle that the tilted array faces in degrees, clockwise from north
+        albedo: The fraction of sunlight that the ground reflects onto the array
+
+        note: For a flat array (array_tilt = 0), this is the same as the GHI
+
+        Returns: The plane of array irradiance in W/m2
+        """
+
+        zenith_angle = np.radians(zenith_angle)
+        array_tilt = np.radians(array_tilt)
+
+        cos_incidence_angle = np.cos(zenith_angle) * np.cos(array_tilt) + \
+            np.sin(zenith_angle) * np.sin(array_tilt) * np.cos(np.radians(azimuth_angle - array_azimuth))
+
+        # the array receives no direct sunlight when the sun is behind it
+        direct_irradiance = DNI * np.clip(cos_incidence_angle, a_min=0, a_max=None)
+
+        diffuse_irradiance = DHI * (1 + np.cos(array_tilt)) / 2
+
+        GHI = DNI * np.clip(np.cos(zenith_angle), a_min=0, a_max=None) + DHI
+        reflected_irradiance = GHI * albedo * (1 - np.cos(array_tilt)) / 2
+
+        return direct_irradiance + diffuse_irradiance + reflected_irradiance
+
+    def decompose_GHI(self, GHI, extraterrestrial_irradiance, zenith_angle):
+        """
+        Splits the Global Horizontal Irradiance into its direct normal and diffuse horizontal
+        components using the Erbs clearness index correlation
+        https://pvlib-python.readthedocs.io/en/stable/reference/generated/pvlib.irradiance.erbs.html
+
+        GHI: The Global Horizontal Irradiance in W/m2
+        extraterrestrial_irradiance: The irradiance normal to the Sun at the top of the atmosphere in W/m2
+        zenith_angle: The zenith angle of the Sun in degrees
+
+        note: The Sun is treated as set once cos(zenith_angle) drops below 0.065 (a zenith angle of
+            about 86 degrees), where all of the irradiance is considered diffuse
+
+        Returns: A tuple of the Direct Normal Irradiance and the Diffuse Horizontal Irradiance in W/m2
+        """
+
+        GHI = np.clip(GHI, a_min=0, a_max=None)
+        cos_zenith = np.cos(np.radians(zenith_angle))
+        min_cos_zenith = 0.065
+
+        # fraction of the sunlight reaching the top of the atmosphere that reaches the ground
+        clearness_index = GHI / (extraterrestrial_irradiance * np.maximum(cos_zenith, min_cos_zenith))
+        clearness_index = np.clip(clearness_index, a_min=0, a_max=1)
+
+        diffuse_fraction = np.where(clearness_index <= 0.22, 1 - 0.09 * clearness_index,
+                                    0.9511 - 0.1604 * clearness_index + 4.388 * clearness_index ** 2
+                                    - 16.638 * clearness_index ** 3 + 12.336 * clearness_index ** 4)
+        diffuse_fraction = np.where(clearness_index > 0.8, 0.165, diffuse_fraction)
+
+        DHI = GHI * np.clip(diffuse_fraction, a_min=0, a_max=1)
+        DNI = np.where(cos_zenith > min_cos_zenith, (GHI - DHI) / np.maximum(cos_zenith, min_cos_zenith), 0)
+
+        return DNI, DHI
+
     # ----- Calculation of modes of solar irradiance, but returning numpy arrays -----
     @helpers.timeit
     def calculate_array_GHI(self, coords, time_zones, local_times,
-                            elevations, cloud_covers):
+                            elevations, cloud_covers, cache_key=None):
 
         """
         Calculates the Global Horizontal Irradiance from the Sun, relative to a location
@@ -274,6 +387,7 @@ class SolarCalculations:
                         (Adjusted for Daylight Savings)
         elevations: (float[N]) elevation from sea level in m
         cloud_covers: (float[N]) percentage cloud cover in range of 0 to 1 
+        cache_key: optional identifier of the local_times array, see decode_local_times
 
         note: If local_times and time_zones are both unadjusted for Daylight Savings, the 
                 calculation will end up just the same
@@ -281,11 +395,39 @@ class SolarCalculations:
         Returns: (float[N]) Global Horizontal Irradiance in W/m2
         """
 
-        day_of_year = np.zeros(len(coords))
-        local_time = np.zeros(len(coords))
-        with tqdm(total=len(coords), file=sys.stdout, desc="Calculating GHI at each time step") as pbar:
-            for i, _ in enumerate(coords):
-                date = datetime.datetime.utcfromtimestamp(local_times[i])
+        day_of_year, local_time = self.decode_local_times(local_times, cache_key)
+
+        ghi = self.calculate_GHI(coords[:, 0], coords[:, 1], time_zones,
+                                 day_of_year, local_time, elevations, cloud_covers)
+        print()
+
+        return ghi
+
+    def decode_local_times(self, local_times, cache_key=None):
+        """
+        Decodes an array of unix timestamps into the day of the year and the local time at each
+        timestamp. Decoding every timestamp is slow, so if the same timestamps are passed in again
+        with the same cache_key, the arrays decoded the first time are returned instead. Only the
+        most recently used cache keys are kept.
+
+        local_times: (int[N]) unix time that the vehicle will be at each location.
+                        (Adjusted for Daylight Savings)
+        cache_key: optional hashable identifier of the local_times array. The caller must use a
+            different key whenever the timestamps change
+
+        Returns: a tuple of (float[N]) days of the year and (float[N]) local times in hours
+            from midnight
+        """
+
+        if cache_key is not None and cache_key in self.decoded_times_cache:
+            self.decoded_times_cache.move_to_end(cache_key)
+            return self.decoded_times_cache[cache_key]
+
+        day_of_year = np.zeros(len(local_times))
+        local_time = np.zeros(len(local_times))
+        with tqdm(total=len(local_times), file=sys.stdout, desc="Calculating GHI at each time step") as pbar:
+            for i, local_unix_time in enumerate(local_times):
+                date = datetime.datetime.utcfromtimestamp(local_unix_time)
 
                 day_of_year[i] = helpers.get_day_of_year(date.day, date.month, date.year)
 
@@ -293,8 +435,11 @@ class SolarCalculations:
 
                 pbar.update(1)
 
-        ghi = self.calculate_GHI(coords[:, 0], coords[:, 1], time_zones,
-                                 day_of_year, local_time, elevations, cloud_covers)
-        print()
+        if cache_key is not None:
+            self.decoded_times_cache[cache_key] = (day_of_year, local_time)
+
+            # evict the least recently used arrays
+            if len(self.decoded_times_cache) > self.decoded_times_cache_size:
+                self.decoded_times_cache.popitem(last=False)
 
-        return ghi
\ No newline at end of file
+        return day_of_year, local_time
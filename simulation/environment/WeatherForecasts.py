@@ -235,8 +235,24 @@ class WeatherForecasts:
 
         return weather_forecast
 
-    def calculate_closest_weather_indices(self, cumulative_distances):
+    def calculate_closest_weather_indices(self, cumulative_distances, looped=False, route_length=None):
+        """
+        Takes in an array of cumulative distances and figures out which of the weather coordinates is closest to the
+        vehicle at each time step.
+
+        :param cumulative_distances: (float[N]) distance the vehicle has travelled by each time step in m
+        :param looped: (bool) if True, the route is a loop (such as the FSGP track) and distances wrap back to the
+            start of the route rather than clamping at the last weather coordinate
+        :param route_length: (float) length of one lap of the route in m, used when looped is True. Defaults to the
+            length of the closed loop through the weather coordinates
+
+        :returns: (int[N]) coordinate indices of self.weather_forecast
+        """
+
+        helpers.check_finite(cumulative_distances, "cumulative_distances")
+
         current_coordinate_index = 0
+        previous_distance = 0
         result = []
 
         # TODO: can rewrite this to use self.gis.path[closest_gis_indices]
@@ -271,8 +287,21 @@ class WeatherForecasts:
         # contains the average distance between two consecutive elements in the cumulative_weather_path_distances array
         average_distances = np.abs(np.diff(cumulative_weather_path_distances) / 2)
 
+        if looped:
+            if route_length is None:
+                # a lap also includes the segment from the last weather coordinate back to the first
+                closing_distance = helpers.calculate_path_distances(weather_coords[[-1, 0]])[0]
+                route_length = np.sum(weather_path_distances) + closing_distance
+            cumulative_distances = np.mod(cumulative_distances, route_length)
+
         for distance in np.nditer(cumulative_distances):
 
+            # the vehicle has crossed the start/finish line, so begin again from the first weather coordinate
+            if looped:
+                if distance < previous_distance:
+                    current_coordinate_index = 0
+                previous_distance = distance
+
             # makes sure the current_coordinate_index does not exceed its maximum value
             if current_coordinate_index > len(average_distances) - 1:
                 current_coordinate_index = len(average_distances) - 1
@@ -286,18 +315,17 @@ class WeatherForecasts:
 
         return np.array(result)
 
-    def get_weather_forecast_in_time(self, indices, unix_timestamps):
+    def calculate_closest_timestamp_indices(self, indices, unix_timestamps, tie_break="lowest"):
         """
         Takes in an array of indices of the weather_forecast array, and an array of timestamps. Uses those to figure out
-        what the weather forecast is at each time step being simulated.
+        which of the available forecast times is closest to each time step being simulated.
 
         :param indices: (int[N]) coordinate indices of self.weather_forecast
         :param unix_timestamps: (int[N]) unix timestamps of the vehicle's journey
+        :param tie_break: (string) which forecast time to choose when a timestamp is exactly halfway between two of
+            them, either "lowest" for the earlier forecast or "highest" for the later, more recent one
 
-        :returns
-        - A numpy array of size [N][9]
-        - [9]: (latitude, longitude, unix_time, timezone_offset, unix_time_corrected, wind_speed, wind_direction,
-                    cloud_cover, precipitation, description)
+        :returns: (int[N]) indices into the time axis of self.weather_forecast
         """
 
         """
@@ -311,9 +339,11 @@ class WeatherForecasts:
         below code is accomplishing.
         """
 
-        # each element is the weather forecast for all available times at that coordinate
-        full_weather_forecast_at_coords = self.weather_forecast[indices]
-        dt_local_array = full_weather_forecast_at_coords[0, :, 4]
+        # the forecast times are shared by all coordinates, so those of the first coordinate are used
+        dt_local_array = self.weather_forecast[indices[0], :, 4]
+
+        helpers.check_finite(dt_local_array, "dt_local_array")
+        helpers.check_finite(unix_timestamps, "unix_timestamps")
 
         closest_time_stamp_indices = []
 
@@ -322,11 +352,78 @@ class WeatherForecasts:
         for unix_timestamp in unix_timestamps:
             unix_timestamp_array = np.full_like(dt_local_array, fill_value=unix_timestamp)
             differences = np.abs(unix_timestamp_array - dt_local_array)
-            minimum_index = np.argmin(differences)
+
+            # np.argmin returns the first minimum, so search the reversed differences to find the last one
+            if tie_break == "highest":
+                minimum_index = len(differences) - 1 - np.argmin(differences[::-1])
+            else:
+                minimum_index = np.argmin(differences)
             closest_time_stamp_indices.append(minimum_index)
 
+        return np.asarray(closest_time_stamp_indices, dtype=np.int32)
+
+    def calculate_bracketing_timestamp_indices(self, indices, unix_timestamps):
+        """
+        Takes in an array of indices of the weather_forecast array, and an array of timestamps. Instead of the single
+        closest forecast time, returns the forecast times immediately before and after each time step, so that the
+        weather can be linearly interpolated between them. Where a timestamp falls exactly on a forecast time, or
+        outside of the forecast range, both indices are the same.
+
+        :param indices: (int[N]) coordinate indices of self.weather_forecast
+        :param unix_timestamps: (int[N]) unix timestamps of the vehicle's journey
+
+        :returns: a tuple of (int[N]) lower and (int[N]) upper indices into the time axis of self.weather_forecast
+        """
+
+        # the forecast times are shared by all coordinates, so those of the first coordinate are used
+        dt_local_array = self.weather_forecast[indices[0], :, 4]
+
+        helpers.check_finite(dt_local_array, "dt_local_array")
+        helpers.check_finite(unix_timestamps, "unix_timestamps")
+
+        last_time_index = len(dt_local_array) - 1
+
+        lower_indices = np.clip(np.searchsorted(dt_local_array, unix_timestamps, side="right") - 1, 0, last_time_index)
+        upper_indices = np.clip(np.searchsorted(dt_local_array, unix_timestamps, side="left"), 0, last_time_index)
+
+        return lower_indices, upper_indices
+
+    def calculate_flattened_weather_indices(self, indices, unix_timestamps):
+        """
+        Takes in an array of indices of the weather_forecast array, and an array of timestamps. Combines the coordinate
+        index and the closest time index at each time step into a single index of the flattened (coordinate x time)
+        weather grid, so that self.weather_forecast.reshape(-1, 9)[result] is the weather at each time step.
+
+        :param indices: (int[N]) coordinate indices of self.weather_forecast
+        :param unix_timestamps: (int[N]) unix timestamps of the vehicle's journey
+
+        :returns: (int[N]) flattened indices, equal to coordinate_index * T + time_index
+        """
+
+        closest_time_stamp_indices = self.calculate_closest_timestamp_indices(indices, unix_timestamps)
+
+        return np.asarray(indices) * self.weather_forecast.shape[1] + closest_time_stamp_indices
+
+    def get_weather_forecast_in_time(self, indices, unix_timestamps):
+        """
+        Takes in an array of indices of the weather_forecast array, and an array of timestamps. Uses those to figure out
+        what the weather forecast is at each time step being simulated.
+
+        :param indices: (int[N]) coordinate indices of self.weather_forecast
+        :param unix_timestamps: (int[N]) unix timestamps of the vehicle's journey
+
+        :returns
+        - A numpy array of size [N][9]
+        - [9]: (latitude, longitude, unix_time, timezone_offset, unix_time_corrected, wind_speed, wind_direction,
+                    cloud_cover, precipitation, description)
+        """
+
+        # each element is the weather forecast for all available times at that coordinate
+        full_weather_forecast_at_coords = self.weather_forecast[indices]
+
+        closest_time_stamp_indices = self.calculate_closest_timestamp_indices(indices, unix_timestamps)
+
         #start_time_shift = np.where(full_weather_forecast_at_coords[:, 4] == self.time_of_initialization)[0][0]
-        closest_time_stamp_indices = np.asarray(closest_time_stamp_indices, dtype=np.int32)
 
         temp_0 = np.arange(0, full_weather_forecast_at_coords.shape[0])
 
@@ -336,6 +433,45 @@ class WeatherForecasts:
 
         return result
 
+    @staticmethod
+    def resample_weather(source_timestamps, source_values, unix_timestamps):
+        """
+        Linearly interpolates a weather quantity that is only known at irregular (e.g. hourly or 3-hourly)
+        forecast times onto the timestamps of the vehicle's journey. Unlike get_weather_forecast_in_time, which
+        picks the closest forecast, this blends the two forecasts on either side of each timestamp. Timestamps
+        outside of the forecast range take the value of the first or last forecast.
+
+        :param source_timestamps: (int[M]) increasing unix timestamps of the forecasts
+        :param source_values: (float[M]) value of the weather quantity (e.g. wind speed) at each forecast
+        :param unix_timestamps: (int[N]) unix timestamps of the vehicle's journey
+
+        :returns: (float[N]) interpolated value of the weather quantity at each timestamp
+        """
+
+        return np.interp(unix_timestamps, source_timestamps, source_values)
+
+    @staticmethod
+    def blend_weather(forecast_values, historical_values, weights):
+        """
+        Blends a forecast weather quantity with its historical (climatological) value, trusting the forecast
+        more where it is more certain.
+
+        :param forecast_values: (float[N]) forecast value of the weather quantity (e.g. wind speed) at each time step
+        :param historical_values: (float[N]) historical value of the same weather quantity at each time step
+        :param weights: (float[N]) confidence in the forecast at each time step, between 0 (use only the
+            historical value) and 1 (use only the forecast)
+
+        :returns: (float[N]) blended value of the weather quantity at each time step
+        """
+
+        weights = np.asarray(weights, dtype=float)
+
+        if np.any((weights < 0) | (weights > 1)):
+            raise ValueError(f"\"weights\" argument is invalid. All weights must be between 0 and 1, "
+                             f"but the range is [{np.min(weights)}, {np.max(weights)}]")
+
+        return weights * forecast_values + (1 - weights) * historical_values
+
     @staticmethod
     def cull_dataset(coords, reduction_factor):
         """
@@ -368,7 +504,8 @@ class WeatherForecasts:
         # wind direction is 90 degrees meteorlogical, so it is 270 degrees azimuthal. car is 90 degrees
         #   cos(90 - 90) = cos(0) = 1. Wind speed is moving opposite to the car,
         # car is 270 degrees, cos(90-270) = -1. Wind speed is in direction of the car.
-        return wind_speeds * (np.cos(np.radians(wind_directions - vehicle_bearings)))
+        return wind_speeds * (np.cos(np.radians(helpers.calculate_angular_difference(wind_directions,
+                                                                                     vehicle_bearings))))
 
     @staticmethod
     def get_weather_advisory(weather_id):
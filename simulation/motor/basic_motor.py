@@ -1,6 +1,7 @@
 import math
 import numpy as np
 
+from simulation.common import helpers
 from simulation.motor.base_motor import BaseMotor
 
 
@@ -76,8 +77,9 @@ class BasicMotor(BaseMotor):
         required_speed_ms = required_speed_kmh / 3.6
         required_angular_speed_rads = required_speed_ms / self.tire_radius
 
+        airspeed = helpers.calculate_airspeed(required_speed_kmh, wind_speed)
         drag_force = 0.5 * self.air_density * (
-                (required_speed_ms + wind_speed) ** 2) * self.drag_coefficient * self.vehicle_frontal_area
+                airspeed ** 2) * self.drag_coefficient * self.vehicle_frontal_area
 
         g_force = self.vehicle_mass * self.acceleration_g * gradient
 
@@ -107,13 +109,13 @@ class BasicMotor(BaseMotor):
 
         :param motor_angular_speed: (float[N]) angular speed motor operates in rad/s
         :param motor_output_energy: (float[N]) energy motor outputs to the wheel in J
-        :param tick: length of 1 update cycle in seconds
+        :param tick: (float or float[N]) length of 1 update cycle, or of each update cycle, in seconds
 
         :returns e_m: (float[N]) efficiency of the motor
         """
 
         # Power = Energy / Time
-        motor_output_power = motor_output_energy * tick
+        motor_output_power = motor_output_energy / tick
         rads_rpm_conversion_factor = 30 / math.pi
 
         revolutions_per_minute = motor_angular_speed * rads_rpm_conversion_factor
@@ -141,7 +143,7 @@ class BasicMotor(BaseMotor):
 
         :param motor_angular_speed: (float[N]) angular speed motor operates in rad/s
         :param motor_output_energy: (float[N]) energy motor outputs to the wheel in J
-        :param tick: length of 1 update cycle in seconds
+        :param tick: (float or float[N]) length of 1 update cycle, or of each update cycle, in seconds
 
         :returns e_mc (float[N]) efficiency of the motor controller
 
@@ -174,6 +176,97 @@ class BasicMotor(BaseMotor):
 
         return e_mc
 
+    @staticmethod
+    def interpolate_motor_efficiency(motor_angular_speed, motor_torque, angular_speed_breakpoints,
+                                     torque_breakpoints, efficiency_table):
+        """
+        Calculates a NumPy array of motor efficiency by bilinearly interpolating a lookup table of
+            measured efficiencies. Operating points outside of the table are clamped to its edges.
+
+        :param motor_angular_speed: (float[N]) angular speed motor operates in rad/s
+        :param motor_torque: (float[N]) torque the motor outputs in Nm
+        :param angular_speed_breakpoints: (float[S]) increasing angular speeds of the table's rows in rad/s
+        :param torque_breakpoints: (float[T]) increasing torques of the table's columns in Nm
+        :param efficiency_table: (float[S][T]) motor efficiency at every (angular speed, torque) pair
+
+        :returns e_m: (float[N]) efficiency of the motor
+        """
+
+        angular_speed_breakpoints = np.asarray(angular_speed_breakpoints, dtype=float)
+        torque_breakpoints = np.asarray(torque_breakpoints, dtype=float)
+        efficiency_table = np.asarray(efficiency_table, dtype=float)
+
+        angular_speeds = np.clip(motor_angular_speed, angular_speed_breakpoints[0], angular_speed_breakpoints[-1])
+        torques = np.clip(motor_torque, torque_breakpoints[0], torque_breakpoints[-1])
+
+        # indices of the lower breakpoints of the table cell that each operating point falls into
+        i = np.clip(np.searchsorted(angular_speed_breakpoints, angular_speeds, side="right") - 1,
+                    0, len(angular_speed_breakpoints) - 2)
+        j = np.clip(np.searchsorted(torque_breakpoints, torques, side="right") - 1,
+                    0, len(torque_breakpoints) - 2)
+
+        speed_weights = (angular_speeds - angular_speed_breakpoints[i]) / \
+            (angular_speed_breakpoints[i + 1] - angular_speed_breakpoints[i])
+        torque_weights = (torques - torque_breakpoints[j]) / (torque_breakpoints[j + 1] - torque_breakpoints[j])
+
+        e_m = (1 - speed_weights) * (1 - torque_weights) * efficiency_table[i, j] \
+            + speed_weights * (1 - torque_weights) * efficiency_table[i + 1, j] \
+            + (1 - speed_weights) * torque_weights * efficiency_table[i, j + 1] \
+            + speed_weights * torque_weights * efficiency_table[i + 1, j + 1]
+
+        return e_m
+
+    @staticmethod
+    def apply_efficiency(powers, efficiencies, min_efficiency=0.01):
+        """
+        Adjusts the power at the wheels by a per-tick efficiency (such as from interpolate_motor_efficiency)
+            to find the power drawn from or returned to the battery. When motoring, losses mean more power is
+            drawn than reaches the wheels, and when regenerating, less power is returned than is braked.
+
+        :param powers: (float[N]) power at the wheels in W, where < 0 means regenerative braking
+        :param efficiencies: (float[N]) efficiency of the motor and motor controller at each tick
+        :param min_efficiency: (float) efficiencies are clamped to at least this value, to avoid dividing by zero
+
+        returns: (float[N]) power at the battery in W, where < 0 means power returned to the battery
+        """
+
+        efficiencies = np.clip(efficiencies, a_min=min_efficiency, a_max=None)
+
+        return np.where(powers > 0, powers / efficiencies, powers * efficiencies)
+
+    @staticmethod
+    def calculate_motor_rpm(speed_kmh, tire_radius, gear_ratio=1):
+        """
+        Calculates the speed the motor turns at from the ground speed of the vehicle
+
+        :param speed_kmh: (float[N]) speed of the vehicle in km/h
+        :param tire_radius: (float) radius of the wheels in m
+        :param gear_ratio: (float) motor revolutions per wheel revolution, which is 1 for a hub motor
+
+        returns: (float[N]) speed of the motor in revolutions per minute
+        """
+
+        wheel_angular_speed_rads = (speed_kmh / 3.6) / tire_radius
+
+        return wheel_angular_speed_rads * gear_ratio * 30 / math.pi
+
+    @staticmethod
+    def calculate_speed_from_rpm(motor_rpm, tire_radius, gear_ratio=1):
+        """
+        Calculates the ground speed of the vehicle from the speed the motor turns at. This is the
+            inverse of calculate_motor_rpm
+
+        :param motor_rpm: (float[N]) speed of the motor in revolutions per minute
+        :param tire_radius: (float) radius of the wheels in m
+        :param gear_ratio: (float) motor revolutions per wheel revolution, which is 1 for a hub motor
+
+        returns: (float[N]) speed of the vehicle in km/h
+        """
+
+        wheel_angular_speed_rads = motor_rpm / gear_ratio * math.pi / 30
+
+        return wheel_angular_speed_rads * tire_radius * 3.6
+
     def calculate_energy_in(self, required_speed_kmh, gradients, wind_speeds, tick):
         """
         Create a function which takes in array of elevation, array of wind speed, required
@@ -182,7 +275,7 @@ class BasicMotor(BaseMotor):
         :param required_speed_kmh: (float[N]) required speed array in km/h
         :param gradients: (float[N]) gradient at parts of the road
         :param wind_speeds: (float[N]) speeds of wind in m/s, where > 0 means against the direction of the vehicle
-        :param tick: (int) length of 1 update cycle in seconds
+        :param tick: (float or float[N]) length of 1 update cycle, or of each update cycle, in seconds
 
         returns: (float[N]) energy expended by the motor at every tick
         """
@@ -192,8 +285,9 @@ class BasicMotor(BaseMotor):
         required_angular_speed_rads = required_speed_ms / self.tire_radius
         required_angular_speed_rads_array = np.ones(len(gradients)) * required_angular_speed_rads
 
+        airspeeds = helpers.calculate_airspeed(required_speed_kmh, wind_speeds)
         drag_forces = 0.5 * self.air_density * (
-                (required_speed_ms + wind_speeds) ** 2) * self.drag_coefficient * self.vehicle_frontal_area
+                airspeeds ** 2) * self.drag_coefficient * self.vehicle_frontal_area
 
         angles = np.arctan(gradients)
         g_forces = self.vehicle_mass * self.acceleration_g * np.sin(angles)
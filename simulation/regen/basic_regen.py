@@ -1,3 +1,5 @@
+import numpy as np
+
 from simulation.regen.base_regen import BaseRegen
 
 
@@ -8,3 +10,22 @@ class BasicRegen(BaseRegen):
     def update(self, tick):
         self.produced_energy = 0
         return self.produced_energy * tick
+
+    @staticmethod
+    def calculate_regen_energy(tractive_powers, regen_efficiency, max_regen_power, tick):
+        """
+        Returns a NumPy array with the energy drawn from the battery at each tick once regenerative
+            braking is accounted for. Positive (motoring) power passes through unchanged, while
+            negative (braking) power is recovered at regen_efficiency, up to max_regen_power.
+
+        :param tractive_powers: (float[N]) power at the wheels in W, where < 0 means braking
+        :param regen_efficiency: (float) fraction of the braking power that is recovered, between 0 and 1
+        :param max_regen_power: (float) maximum power the regen system can recover in W
+        :param tick: (float or float[N]) the duration of each time step in seconds, either uniform or per time step
+
+        :returns: (float[N]) energy at each tick in J, where < 0 means energy recovered into the battery
+        """
+
+        recovered_powers = np.maximum(tractive_powers * regen_efficiency, -max_regen_power)
+
+        return np.where(tractive_powers < 0, recovered_powers, tractive_powers) * tick
@@ -35,6 +35,64 @@ def test_calculate_closest_gis_indices(gis):
     assert np.all(result == np.array([0, 0, 1, 1, 1, 2, 2, 2, 2, 3, 3]))
 
 
+def test_calculate_route_overshoot(gis):
+    test_cumulative_distances = np.array([0, 100, 239, 240, 241, 300])
+    test_path_distances = np.repeat(20, 13)
+    test_path_distances[0] = 0
+
+    gis.path_distances = test_path_distances
+
+    result = gis.calculate_route_overshoot(test_cumulative_distances)
+
+    assert np.all(result == np.array([False, False, False, False, True, True]))
+
+
+def test_calculate_closest_gis_indices_rejects_nan(gis):
+    test_cumulative_distances = np.array([0, 9, np.inf, 19])
+
+    with pytest.raises(simulation.common.NonFiniteInputError) as error:
+        gis.calculate_closest_gis_indices(test_cumulative_distances)
+
+    assert error.value.index == 2
+
+
+def test_calculate_closest_gis_indices_chunk(gis):
+    test_cumulative_distances = np.array([0, 9, 18, 19, 27, 35, 38, 47, 48, 56, 63])
+    test_path_distances = np.repeat(20, 13)
+    test_path_distances[0] = 0
+
+    gis.path_distances = test_path_distances
+
+    expected = gis.calculate_closest_gis_indices(test_cumulative_distances)
+
+    first_chunk, current_coordinate_index = gis.calculate_closest_gis_indices_chunk(test_cumulative_distances[:5])
+    second_chunk, _ = gis.calculate_closest_gis_indices_chunk(test_cumulative_distances[5:], current_coordinate_index)
+
+    assert np.all(np.concatenate((first_chunk, second_chunk)) == expected)
+
+
+def test_calculate_closest_gis_indices_reverse(gis):
+    test_cumulative_distances = np.array([0, 9, 18, 19, 27, 35, 38, 47, 48, 56, 63])
+
+    gis.path_distances = np.repeat(20, 13)
+
+    forward = gis.calculate_closest_gis_indices(test_cumulative_distances)
+    reverse = gis.calculate_closest_gis_indices(test_cumulative_distances, reverse=True)
+
+    # the route is symmetric, so driving it in reverse visits the mirror image of the forward indices,
+    # starting from the last of the 14 path coordinates
+    last_coordinate_index = 13
+    assert reverse[0] == last_coordinate_index
+    assert np.all(reverse == last_coordinate_index - forward)
+
+    first_chunk, current_coordinate_index = gis.calculate_closest_gis_indices_chunk(test_cumulative_distances[:5],
+                                                                                    reverse=True)
+    second_chunk, _ = gis.calculate_closest_gis_indices_chunk(test_cumulative_distances[5:],
+                                                              current_coordinate_index, reverse=True)
+
+    assert np.all(np.concatenate((first_chunk, second_chunk)) == reverse)
+
+
 # def test_calculate_time_zones(gis):
 #     raise NotImplementedError
 
@@ -49,3 +107,37 @@ def test_calculate_closest_gis_indices(gis):
 
 # def test_calculate_path_gradients(gis):
 #     raise NotImplementedError
+
+
+def test_snap_distances_to_path_indices(gis):
+    # path coordinates every 20m, from 0m to 240m
+    gis.path_distances = np.repeat(20, 12)
+
+    test_distances = np.array([29, 31, 30, 0, -5, 500])
+
+    result = gis.snap_distances_to_path_indices(test_distances)
+
+    # the route end is clamped to the last index that calculate_closest_gis_indices returns
+    assert np.all(result == np.array([1, 2, 1, 0, 0, 10]))
+
+
+def test_snap_distances_to_path_indices_route_end(gis):
+    gis.path_distances = np.repeat(20, 12)
+
+    # waypoints at the final two path coordinates, 220m and 240m along the route
+    snapped_indices = gis.snap_distances_to_path_indices(np.array([220, 240]))
+    gis_indices = gis.calculate_closest_gis_indices(np.arange(0, 241))
+
+    assert np.all(np.isin(snapped_indices, gis_indices))
+
+
+def test_calculate_dwell_counts(gis):
+    test_cumulative_distances = np.array([0, 9, 18, 19, 27, 35, 38, 47, 48, 56, 63])
+    gis.path_distances = np.repeat(20, 12)
+
+    gis_indices = gis.calculate_closest_gis_indices(test_cumulative_distances)
+    result = gis.calculate_dwell_counts(gis_indices)
+
+    assert len(result) == 13
+    assert np.sum(result) == len(test_cumulative_distances)
+    assert np.all(result == np.histogram(gis_indices, bins=np.arange(14))[0])
@@ -0,0 +1,89 @@
+import numpy as np
+import pytest
+import simulation
+
+
+def test_calculate_temperature_derated_power():
+    solar_irradiances = np.array([1000, 1000, 1000])
+    cell_temperatures = np.array([25, 45, 300])
+
+    result = simulation.BasicArray.calculate_temperature_derated_power(solar_irradiances, cell_temperatures,
+                                                                       panel_efficiency=0.2,
+                                                                       temperature_coefficient=-0.004,
+                                                                       panel_size=6)
+
+    # no derate at 25°C, 8% less power at 45°C, and no negative power at unphysical temperatures
+    assert np.allclose(result, [1200, 1104, 0])
+
+
+def test_calculate_temperature_derated_power_incidence_angles():
+    solar_irradiances = np.full(3, 1000)
+    cell_temperatures = np.full(3, 25)
+    incidence_angles = np.array([0, 60, 95])
+
+    result = simulation.BasicArray.calculate_temperature_derated_power(solar_irradiances, cell_temperatures,
+                                                                       panel_efficiency=0.2,
+                                                                       temperature_coefficient=-0.004,
+                                                                       panel_size=6,
+                                                                       incidence_angles=incidence_angles)
+
+    # full power facing the sun, half at 60 degrees, and none with the sun behind the panels
+    assert np.allclose(result, [1200, 600, 0])
+
+    # an incidence angle modifier that loses a further 10% at 60 degrees
+    result = simulation.BasicArray.calculate_temperature_derated_power(solar_irradiances, cell_temperatures,
+                                                                       panel_efficiency=0.2,
+                                                                       temperature_coefficient=-0.004,
+                                                                       panel_size=6,
+                                                                       incidence_angles=incidence_angles,
+                                                                       iam_coefficients=[1, -0.1 / 60])
+
+    assert np.allclose(result, [1200, 540, 0])
+
+
+def test_calculate_cell_temperature():
+    ambient_temperatures = np.array([20, 20, 30])
+    solar_irradiances = np.array([0, 800, 1000])
+
+    result = simulation.BasicArray.calculate_cell_temperature(ambient_temperatures, solar_irradiances, noct=45)
+
+    # cells are at ambient temperature in the dark and reach the NOCT at 800W/m2 and 20°C
+    assert np.allclose(result, [20, 45, 61.25])
+
+
+def test_calculate_cell_temperature_airspeeds():
+    ambient_temperatures = np.full(3, 20)
+    solar_irradiances = np.full(3, 800)
+    airspeeds = np.array([0, 10, 25])
+
+    result = simulation.BasicArray.calculate_cell_temperature(ambient_temperatures, solar_irradiances, noct=45,
+                                                              airspeeds=airspeeds, convective_coefficient=0.25)
+
+    # still air matches the NOCT model, and faster air cools the cells towards ambient
+    assert np.allclose(result, [45, 27.142857, 23.448276])
+    assert result[0] > result[1] > result[2] > 20
+
+
+def test_calculate_produced_energy_non_uniform_tick():
+    basic_array = simulation.BasicArray()
+
+    fine_energy = basic_array.calculate_produced_energy(np.full(60, 800), tick=1)
+    coarse_energy = basic_array.calculate_produced_energy(np.full(6, 800), tick=10)
+    mixed_energy = basic_array.calculate_produced_energy(np.full(3, 800), tick=np.array([10, 20, 30]))
+
+    assert np.isclose(np.sum(coarse_energy), np.sum(fine_energy))
+    assert np.isclose(np.sum(mixed_energy), np.sum(fine_energy))
+
+
+def test_apply_derate():
+    produced_powers = np.array([1000, 500, 0])
+
+    result = simulation.BasicArray.apply_derate(produced_powers, np.full(3, 0.9))
+
+    assert np.allclose(result, [900, 450, 0])
+
+    with pytest.raises(ValueError):
+        simulation.BasicArray.apply_derate(produced_powers, np.array([0.9, 1.1, 0.9]))
+
+    with pytest.raises(ValueError):
+        simulation.BasicArray.apply_derate(produced_powers, np.array([0.9, -0.1, 0.9]))
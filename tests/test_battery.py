@@ -0,0 +1,39 @@
+import numpy as np
+import simulation
+
+
+def test_calculate_resistive_losses():
+    temperature_breakpoints = np.array([0, 25, 45])
+    resistance_breakpoints = np.array([0.3, 0.1, 0.08])
+
+    currents = np.full(3, 20)
+    temperatures = np.array([25, 0, -10])
+
+    result = simulation.BasicBattery.calculate_resistive_losses(currents, temperatures, temperature_breakpoints,
+                                                                resistance_breakpoints)
+
+    # 40W at the reference temperature, three times that in the cold, clamped below the coldest measurement
+    assert np.allclose(result, [40, 120, 120])
+    assert result[1] > result[0]
+
+
+def test_calculate_minimum_soc():
+    soc_array = np.array([0.9, 0.7, 0.4, 0.55, 0.4, 0.6])
+
+    minimum_soc, minimum_soc_index = simulation.BasicBattery.calculate_minimum_soc(soc_array)
+
+    assert minimum_soc == 0.4
+    assert minimum_soc_index == 2
+
+
+def test_calculate_charge_time():
+    # 500Wh of charge at a constant 6kW takes 5 minutes
+    array_powers = np.full(900, 6000)
+
+    assert simulation.BasicBattery.calculate_charge_time(0.5, 0.625, 4000, array_powers) == 300
+    assert simulation.BasicBattery.calculate_charge_time(0.5, 0.75, 4000, array_powers) == 600
+    assert simulation.BasicBattery.calculate_charge_time(0.5, 0.75, 4000, np.full(90, 6000), tick=10) == 600
+
+    # already charged, and more charge than the stop is long enough for
+    assert simulation.BasicBattery.calculate_charge_time(0.6, 0.5, 4000, array_powers) == 0
+    assert simulation.BasicBattery.calculate_charge_time(0.5, 0.75, 4000, np.full(599, 6000)) == -1
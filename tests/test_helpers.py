@@ -1,4 +1,5 @@
 import numpy as np
+import pytest
 from simulation.common import helpers
 
 
@@ -10,3 +11,591 @@ def test_checkForNonConsecutiveZeros():
         (helpers.checkForNonConsecutiveZeros(test_array_true), helpers.checkForNonConsecutiveZeros(test_array_false)))
 
     assert result == (True, False)
+
+
+def test_calculate_path_bearings():
+    due_north = np.array([[0, 0], [1, 0]])
+    due_east = np.array([[0, 0], [0, 1]])
+    diagonal = np.array([[0, 0], [1, 1], [2, 2]])
+
+    assert np.allclose(helpers.calculate_path_bearings(due_north), [0, 0])
+    assert np.allclose(helpers.calculate_path_bearings(due_east), [90, 90])
+
+    diagonal_bearings = helpers.calculate_path_bearings(diagonal)
+    assert np.allclose(diagonal_bearings, 45, atol=0.05)
+    assert diagonal_bearings[-1] == diagonal_bearings[-2]
+
+
+def test_calculate_haversine_path_distances():
+    # London -> Paris -> New York; reference great-circle distances are 343.5km and 5837km
+    coords = np.array([[51.5074, -0.1278], [48.8566, 2.3522], [40.7128, -74.0060]])
+
+    result = helpers.calculate_haversine_path_distances(coords)
+
+    assert np.allclose(result, [343.5e3, 5837e3], rtol=0.005)
+
+    # New York -> Los Angeles in a single call, with a reference distance of 3936km
+    result = helpers.calculate_haversine_distances(np.array([40.7128, -74.0060]), np.array([34.0522, -118.2437]))
+
+    assert np.isclose(result, 3936e3, rtol=0.005)
+
+
+def test_calculate_completed_laps():
+    # 10m/s for 300s around a 1km track
+    total_distance, completed_laps, final_lap_distance = \
+        helpers.calculate_completed_laps(np.full(300, 36), track_length=1000)
+
+    assert total_distance == pytest.approx(3000)
+    assert completed_laps == 3
+    assert final_lap_distance == pytest.approx(0)
+
+    # 10m/s for 350s, finishing half-way around the fourth lap
+    total_distance, completed_laps, final_lap_distance = \
+        helpers.calculate_completed_laps(np.full(350, 36), track_length=1000)
+
+    assert total_distance == pytest.approx(3500)
+    assert completed_laps == 3
+    assert final_lap_distance == pytest.approx(500)
+
+
+def test_calculate_moving_average():
+    test_array = np.array([1, 2, 3, 4, 5])
+
+    assert np.allclose(helpers.calculate_moving_average(test_array, 1), test_array)
+    assert np.allclose(helpers.calculate_moving_average(test_array, 3), [1.5, 2, 3, 4, 4.5])
+    assert np.allclose(helpers.calculate_moving_average(test_array, 2), [1.5, 2.5, 3.5, 4.5, 5])
+
+
+def test_enforce_monotonic():
+    test_array = np.array([0, 10, 9.999, 20, 15, 18, 30])
+
+    result, corrections = helpers.enforce_monotonic(test_array)
+
+    assert np.all(np.diff(result) >= 0)
+    assert np.all(result == np.array([0, 10, 10, 20, 20, 20, 30]))
+    assert corrections == 3
+
+
+def test_check_arrival_windows():
+    arrival_times = np.array([100, 500, 300, 200])
+    earliest_arrival_times = np.array([200, 0, 300, 100])
+    latest_arrival_times = np.array([400, 400, 300, 300])
+
+    result = helpers.check_arrival_windows(arrival_times, earliest_arrival_times, latest_arrival_times)
+
+    assert np.all(result == np.array([-1, 1, 0, 0]))
+
+
+def test_calculate_cumulative_distances():
+    # 10m/s for 10s
+    speed_kmh = np.full(10, 36)
+
+    cumulative_distances, completion_index = helpers.calculate_cumulative_distances(speed_kmh, route_length=50)
+
+    assert np.allclose(cumulative_distances, np.arange(10, 110, 10))
+    assert completion_index == 4
+
+    _, completion_index = helpers.calculate_cumulative_distances(speed_kmh, route_length=1000)
+
+    assert completion_index == -1
+
+
+def test_get_last_timing():
+    @helpers.timeit
+    def timed_function():
+        return np.sum(np.arange(100000))
+
+    assert helpers.get_last_timing("timed_function") is None
+
+    timed_function()
+
+    assert helpers.get_last_timing("timed_function") > 0
+
+
+def test_calculate_angular_difference():
+    angles_1 = np.array([10, 350, 180, 0, 179, -179])
+    angles_2 = np.array([350, 10, 0, 180, -179, 179])
+
+    result = helpers.calculate_angular_difference(angles_1, angles_2)
+
+    assert np.allclose(result, [20, -20, -180, -180, -2, 2])
+
+
+def test_interpolate_path_coordinates():
+    # New York -> London, a segment long enough for the great circle to curve well north of the straight line
+    coords = np.array([[40.7128, -74.0060], [51.5074, -0.1278]])
+
+    result = helpers.interpolate_path_coordinates(coords, np.array([0, 0, 0]), np.array([0, 0.5, 1]))
+
+    # great-circle midpoint from https://www.movable-type.co.uk/scripts/latlong.html
+    lat_1, lng_1 = np.radians(coords[0])
+    lat_2, lng_2 = np.radians(coords[1])
+    b_x = np.cos(lat_2) * np.cos(lng_2 - lng_1)
+    b_y = np.cos(lat_2) * np.sin(lng_2 - lng_1)
+    midpoint_lat = np.arctan2(np.sin(lat_1) + np.sin(lat_2), np.hypot(np.cos(lat_1) + b_x, b_y))
+    midpoint_lng = lng_1 + np.arctan2(b_y, np.cos(lat_1) + b_x)
+
+    assert np.allclose(result[0], coords[0])
+    assert np.allclose(result[1], np.degrees([midpoint_lat, midpoint_lng]))
+    assert np.allclose(result[2], coords[1])
+
+
+def test_gather_elevations_and_gradients():
+    # a 2% ramp, with 100m between coordinates
+    elevations = np.array([0, 2, 4, 6, 8])
+    distances = np.full(4, 100)
+    gis_indices = np.array([0, 0, 1, 3, 4, 4])
+
+    result_elevations, result_gradients = helpers.gather_elevations_and_gradients(gis_indices, elevations, distances)
+
+    assert np.all(result_elevations == np.array([0, 0, 2, 6, 8, 8]))
+    assert np.allclose(result_gradients, 0.02)
+
+
+def test_calculate_cumulative_sum_compensated():
+    # each increment is below half an ulp of 1.0, so a naive running sum never moves off 1.0
+    increments = np.full(100000, 1e-16)
+    input_array = np.insert(increments, 0, 1.0)
+    exact_total = 1.0 + 1e-11
+
+    naive_total = helpers.calculate_cumulative_sum(input_array)[-1]
+    compensated_total = helpers.calculate_cumulative_sum(input_array, compensated=True)[-1]
+
+    assert abs(compensated_total - exact_total) < abs(naive_total - exact_total)
+    assert compensated_total == pytest.approx(exact_total, rel=1e-15)
+    assert np.allclose(helpers.calculate_cumulative_sum(np.arange(5), compensated=True), [0, 1, 3, 6, 10])
+
+
+def test_apply_overnight_holds():
+    speed_kmh = np.arange(1, 11, dtype=float)
+
+    result = helpers.apply_overnight_holds(speed_kmh, np.array([[3, 6]]))
+
+    assert len(result) == len(speed_kmh)
+    assert np.all(result[3:6] == 0)
+
+    # driving before the hold is unchanged and driving after it resumes where it left off
+    assert np.all(result[:3] == speed_kmh[:3])
+    assert np.all(result[6:] == speed_kmh[3:7])
+
+
+def test_calculate_airspeed():
+    speed_kmh = np.array([36.0, 36.0, 0.0])
+    headwind_speeds = np.array([5.0, -5.0, 3.0])
+
+    assert np.allclose(helpers.calculate_airspeed(speed_kmh, headwind_speeds), [15, 5, 3])
+
+
+def test_calculate_effective_airspeed():
+    speed_kmh = np.array([36.0])
+    vehicle_bearings = np.array([0.0])
+    wind_speeds = np.array([5.0])
+
+    # wind coming from the south pushes a northbound car along
+    tailwind_airspeed = helpers.calculate_effective_airspeed(speed_kmh, vehicle_bearings, wind_speeds,
+                                                             np.array([180.0]))
+    headwind_airspeed = helpers.calculate_effective_airspeed(speed_kmh, vehicle_bearings, wind_speeds,
+                                                             np.array([0.0]))
+
+    assert tailwind_airspeed[0] < 10 < headwind_airspeed[0]
+    assert np.isclose(tailwind_airspeed[0], 5)
+    assert np.isclose(headwind_airspeed[0], 15)
+
+
+def test_snap_to_route():
+    route_coords = np.array([[49.26, -123.25], [49.27, -123.24], [49.28, -123.23], [49.29, -123.22]])
+    test_coords = route_coords[[2, 0, 3]]
+
+    route_indices, distances = helpers.snap_to_route(test_coords, route_coords)
+
+    assert np.all(route_indices == np.array([2, 0, 3]))
+    assert np.allclose(distances, 0)
+
+    # a fix slightly off the route still snaps to the closest route coordinate
+    route_indices, distances = helpers.snap_to_route(np.array([[49.271, -123.2405]]), route_coords)
+
+    assert route_indices[0] == 1
+    assert 0 < distances[0] < 200
+
+
+def test_validate_speed_profile():
+    assert helpers.validate_speed_profile(np.array([0, 5, 10, 15, 15]), 100, 5) == (-1, None)
+    assert helpers.validate_speed_profile(np.array([90, 95, 101, 95]), 100, 10) == (2, "speed")
+    assert helpers.validate_speed_profile(np.array([10, 12, 30, 32]), 100, 5) == (2, "acceleration")
+
+
+def test_calculate_cumulative_distances_non_uniform_tick():
+    # 100s at a constant 36km/h, as 1s steps and as a mix of 10s and 30s steps
+    fine_distances, _ = helpers.calculate_cumulative_distances(np.full(100, 36), route_length=10000)
+    coarse_distances, _ = helpers.calculate_cumulative_distances(np.full(5, 36), route_length=10000,
+                                                                 tick=np.array([10, 30, 10, 30, 20]))
+
+    assert np.isclose(fine_distances[-1], 1000)
+    assert np.isclose(coarse_distances[-1], fine_distances[-1])
+    assert np.allclose(coarse_distances, fine_distances[[9, 39, 49, 79, 99]])
+
+
+def test_calculate_cumulative_energy():
+    # an hour at 500W, then 30 minutes regenerating 200W
+    net_powers = np.concatenate([np.full(3600, 500), np.full(1800, -200)])
+
+    consumed_energy, regenerated_energy = helpers.calculate_cumulative_energy(net_powers)
+
+    assert np.isclose(consumed_energy[3599], 500)
+    assert np.isclose(consumed_energy[-1], 500)
+    assert np.isclose(regenerated_energy[3599], 0)
+    assert np.isclose(regenerated_energy[-1], 100)
+
+    # coarser time steps integrate to the same energy
+    consumed_energy, _ = helpers.calculate_cumulative_energy(np.full(6, 500), tick=600)
+    assert np.isclose(consumed_energy[-1], 500)
+
+
+def test_calculate_horizon_shading():
+    # a valley with a 15 degree ridge at the first coordinate and open sky at the second
+    horizon_angles = np.array([15.0, 0.0])
+
+    # early morning, mid-morning and midday sun, all over the ridge, then the early sun at the open coordinate
+    elevation_angles = np.array([5.0, 20.0, 60.0, 5.0])
+    gis_indices = np.array([0, 0, 0, 1])
+
+    result = helpers.calculate_horizon_shading(elevation_angles, horizon_angles, gis_indices)
+
+    assert np.all(result == np.array([0, 1, 1, 1]))
+
+
+def test_get_nonzero_bounds():
+    assert helpers.get_nonzero_bounds(np.zeros(5)) == (-1, -1)
+    assert helpers.get_nonzero_bounds(np.array([0, 0, 30, 40, 50])) == (2, 4)
+    assert helpers.get_nonzero_bounds(np.array([30, 0, 40, 0, 0])) == (0, 2)
+
+
+def test_calculate_haversine_distances_ellipsoidal():
+    # the WGS84 meridian arc from the equator to 10 degrees north is 1105855m long
+    coords_1 = np.array([[0.0, -100.0]])
+    coords_2 = np.array([[10.0, -100.0]])
+
+    spherical_distance = helpers.calculate_haversine_distances(coords_1, coords_2)[0]
+    ellipsoidal_distance = helpers.calculate_haversine_distances(coords_1, coords_2, ellipsoidal=True)[0]
+
+    assert ellipsoidal_distance == pytest.approx(1105855, rel=1e-4)
+    assert abs(ellipsoidal_distance - 1105855) < abs(spherical_distance - 1105855)
+
+    # along the equator the ellipsoidal radius is the semi-major axis
+    equator_distance = helpers.calculate_haversine_distances(np.array([[0.0, 0.0]]), np.array([[0.0, 1.0]]),
+                                                             ellipsoidal=True)[0]
+    assert equator_distance == pytest.approx(111319.5, rel=1e-5)
+
+
+def test_calculate_solar_elevation():
+    # at 49 degrees north, with the Sun at a declination of 20 degrees
+    hour_angles = np.array([0, 45, 180])
+    declination_angles = np.full(3, 20)
+    latitudes = np.full(3, 49)
+
+    elevation_angles, daylight = helpers.calculate_solar_elevation(declination_angles, hour_angles, latitudes)
+
+    # the Sun is highest at local solar noon, and below the horizon at midnight
+    assert elevation_angles[0] == pytest.approx(90 - (49 - 20))
+    assert elevation_angles[0] > elevation_angles[1] > 0
+    assert elevation_angles[2] < 0
+    assert np.all(daylight == np.array([True, True, False]))
+
+
+def test_calculate_required_speeds():
+    # a 100km route with 2 hours to drive it
+    cumulative_distances = np.array([0, 95000, 99000, 99000, 100000])
+    remaining_times = np.array([7200, 600, 60, 0, 0])
+
+    result = helpers.calculate_required_speeds(cumulative_distances, 100000, remaining_times)
+
+    assert result[0] == pytest.approx(50)
+    assert result[1] == pytest.approx(30)
+    assert result[2] == pytest.approx(60)
+    assert result[3] == np.inf
+    assert result[4] == 0
+
+
+def test_calculate_cross_track_errors():
+    # a route going north along the prime meridian, with a fix 0.001 degrees of longitude east of the first segment
+    route_coords = np.array([[0.0, 0.0], [0.01, 0.0], [0.02, 0.0]])
+    test_coords = np.array([[0.005, 0.001], [0.01, 0.0], [-0.001, 0.0]])
+
+    result = helpers.calculate_cross_track_errors(test_coords, route_coords)
+
+    # the perpendicular distance is less than the distance to either route coordinate
+    expected_distance = helpers.calculate_haversine_distances(np.array([0.005, 0.0]), test_coords[0])
+    assert result[0] == pytest.approx(expected_distance, rel=1e-6)
+    assert result[0] < np.min(helpers.snap_to_route(test_coords[:1], route_coords)[1])
+
+    # a fix on the route has no error, and one before the start of the route is measured to its first coordinate
+    assert result[1] == pytest.approx(0, abs=1e-6)
+    assert result[2] == pytest.approx(helpers.calculate_haversine_distances(route_coords[0], test_coords[2]))
+
+
+def test_aggregate_by_segments():
+    input_array = np.array([1, 2, 3, 4, 5, 6, 7, 8, 9, 10])
+    checkpoint_indices = np.array([3, 7])
+
+    sums = helpers.aggregate_by_segments(input_array, checkpoint_indices)
+    means = helpers.aggregate_by_segments(input_array, checkpoint_indices, mode="mean")
+
+    assert np.allclose(sums, [6, 22, 27])
+    assert np.allclose(means, [2, 5.5, 9])
+    assert np.isclose(np.sum(sums), np.sum(input_array))
+
+
+def test_solar_time_to_hour_angle():
+    # noon, 6am and 6pm, then the same times on the following day
+    solar_times = np.array([12, 6, 18, 36, 30, 42])
+
+    result = helpers.solar_time_to_hour_angle(solar_times)
+
+    assert np.allclose(result, np.radians([0, -90, 90, 0, -90, 90]))
+    assert np.all((-np.pi <= result) & (result < np.pi))
+
+
+def test_calculate_speed_derivatives():
+    times = np.arange(10, dtype=float)
+
+    # accelerating at a constant 2 m/s^2
+    acceleration, jerk = helpers.calculate_speed_derivatives(2 * times * 3.6)
+
+    assert np.allclose(acceleration, 2)
+    assert np.allclose(jerk, 0)
+
+    # speed rising as t^2 m/s, sampled every 2 seconds
+    acceleration, jerk = helpers.calculate_speed_derivatives(np.square(2 * times) * 3.6, tick=2)
+
+    assert np.allclose(acceleration, 2 * (2 * times))
+    assert np.allclose(jerk, 2)
+
+
+def test_calculate_statistics():
+    input_array = np.arange(101)
+
+    result = helpers.calculate_statistics(input_array, percentiles=(25, 50, 90))
+
+    assert result["min"] == 0
+    assert result["max"] == 100
+    assert result["mean"] == pytest.approx(50)
+    assert result["std"] == pytest.approx(np.sqrt((101 ** 2 - 1) / 12))
+    assert result["p25"] == pytest.approx(25)
+    assert result["p50"] == pytest.approx(50)
+    assert result["p90"] == pytest.approx(90)
+
+
+def test_calculate_extraterrestrial_irradiance():
+    # close to perihelion in early January, and to aphelion in early July
+    perihelion, aphelion = helpers.calculate_extraterrestrial_irradiance(np.array([3, 185]))
+
+    assert perihelion == pytest.approx(1361 * 1.033, rel=1e-3)
+    assert aphelion == pytest.approx(1361 * 0.967, rel=1e-3)
+    yearly_irradiances = helpers.calculate_extraterrestrial_irradiance(np.arange(1, 366))
+    assert np.all((1361 * 0.967 <= yearly_irradiances) & (yearly_irradiances <= 1361 * 1.033))
+
+
+def test_calculate_lap_times():
+    # just over three and a half laps of a 100m track at 36km/h
+    cumulative_distances, _ = helpers.calculate_cumulative_distances(np.full(35, 36), route_length=1000)
+
+    completion_indices, lap_durations = helpers.calculate_lap_times(cumulative_distances, 100)
+
+    assert np.all(completion_indices == np.array([9, 19, 29]))
+    assert np.allclose(lap_durations, 10)
+
+
+def test_calculate_solar_energy_by_period():
+    # a day of power that is symmetric about solar noon, sampled every 10 minutes
+    solar_times = 6 + (np.arange(72) + 0.5) / 6
+    array_powers = 1000 * np.cos(np.radians(15 * (solar_times - 12)))
+
+    morning_energy, afternoon_energy, total_energy = helpers.calculate_solar_energy_by_period(array_powers,
+                                                                                               solar_times,
+                                                                                               tick=600)
+
+    assert morning_energy == pytest.approx(afternoon_energy)
+    assert total_energy == pytest.approx(np.sum(array_powers) * 600)
+
+
+def test_interpolate_elevations():
+    # a 1% ramp sampled every 1000m, from 500m to 2500m along the route
+    sample_distances = np.array([500, 1500, 2500])
+    sample_elevations = np.array([105, 115, 125])
+
+    # path coordinates every 250m, from 0m to 3000m
+    path_distances = np.full(12, 250)
+
+    result = helpers.interpolate_elevations(sample_distances, sample_elevations, path_distances)
+
+    assert len(result) == 13
+    assert np.allclose(result[2:11], 100 + np.arange(500, 2750, 250) / 100)
+    assert np.allclose(result[:2], 105)
+    assert np.allclose(result[11:], 125)
+
+
+def test_generate_coordinate_cloud_phases():
+    phases = helpers.generate_coordinate_cloud_phases(100, seed=42)
+
+    assert np.all((0 <= phases) & (phases < 1))
+    assert np.array_equal(phases, helpers.generate_coordinate_cloud_phases(100, seed=42))
+    assert not np.array_equal(phases, helpers.generate_coordinate_cloud_phases(100, seed=43))
+
+    # a longer route keeps the phases of the original coordinates
+    assert np.array_equal(phases, helpers.generate_coordinate_cloud_phases(150, seed=42)[:100])
+
+    # the first two outputs of splitmix64 seeded with 0 are 0xE220A8397B1DCDAF and 0x6E789E6AA1B965F4
+    expected_phases = np.array([0xE220A8397B1DCDAF >> 11, 0x6E789E6AA1B965F4 >> 11]) * 2.0 ** -53
+    assert np.array_equal(helpers.generate_coordinate_cloud_phases(2, seed=0), expected_phases)
+
+
+def test_calculate_distances_to_next_checkpoint():
+    cumulative_distances = np.array([0, 50, 100, 150, 250, 300, 350])
+    checkpoint_distances = np.array([100, 300])
+
+    result = helpers.calculate_distances_to_next_checkpoint(cumulative_distances, checkpoint_distances)
+
+    assert np.allclose(result, [100, 50, 0, 150, 50, 0, -1])
+
+
+def test_calculate_power_balance():
+    array_powers = np.array([1000, 1000, 1000, 1000])
+    consumed_powers = np.array([800, 1200, 900, 1500])
+
+    net_powers, deficits = helpers.calculate_power_balance(array_powers, consumed_powers)
+
+    assert np.allclose(net_powers, [200, -200, 100, -500])
+    assert np.all(deficits == np.array([False, True, False, True]))
+
+
+def test_calculate_path_curvatures():
+    # a quarter circle of radius 500m, then a straight
+    angles = np.radians(np.arange(0, 100, 10))
+    radius = 500
+    earth_radius = 6371009
+
+    arc_coords = np.column_stack([49 + np.degrees(radius * np.sin(angles) / earth_radius),
+                                  -123 + np.degrees(radius * np.cos(angles) / (earth_radius * np.cos(np.radians(49))))])
+    straight_coords = arc_coords[-1] + np.outer(np.arange(1, 4), [0, -0.001])
+    coords = np.concatenate([arc_coords, straight_coords])
+
+    curvatures, max_speeds = helpers.calculate_path_curvatures(coords, max_lateral_acceleration=5)
+
+    assert np.allclose(curvatures[1:9], 1 / radius, rtol=1e-3)
+    assert np.allclose(max_speeds[1:9], np.sqrt(5 * radius) * 3.6, rtol=1e-3)
+
+    # the ends of the path, and the straight, have no curvature
+    assert curvatures[0] == 0 and curvatures[-1] == 0
+    assert np.allclose(curvatures[10:12], 0, atol=1e-9)
+    assert max_speeds[0] == np.inf
+
+
+def test_calculate_apparent_solar_time():
+    local_times = np.array([12, 12, 9.5])
+    lstm = np.full(3, -120)
+    eot = np.array([-6, -6, 12])
+
+    # on the standard meridian, 5 degrees west of it, and 15 degrees east of it
+    longitudes = np.array([-120, -125, -105])
+
+    result = helpers.calculate_apparent_solar_time(local_times, longitudes, lstm, eot)
+
+    assert np.allclose(result, [12 - 6 / 60, 12 - 26 / 60, 9.5 + 72 / 60])
+
+    # matches the conversion used by SolarCalculations
+    assert np.allclose(helpers.local_time_to_apparent_solar_time(-8, 100, local_times, longitudes),
+                       helpers.calculate_apparent_solar_time(local_times, longitudes, helpers.calculate_LSTM(-8),
+                                                             helpers.calculate_eot_correction(100)))
+
+
+def test_calculate_time_above_speed():
+    # fast in the first segment, slow in the second, and briefly fast in the last
+    speed_kmh = np.array([90, 95, 100, 85, 60, 70, 65, 50, 95, 40])
+    checkpoint_indices = np.array([4, 8])
+
+    result = helpers.calculate_time_above_speed(speed_kmh, checkpoint_indices, speed_threshold=80)
+
+    assert np.allclose(result, [4, 0, 1])
+    assert np.allclose(helpers.calculate_time_above_speed(speed_kmh, checkpoint_indices, 80, tick=10), [40, 0, 10])
+
+
+def test_smooth_path_gradients():
+    # a staircase climbing 10m in a single 100m segment out of every 5, an average of 2%
+    gradients = np.tile([0.1, 0, 0, 0, 0], 20)
+    distances = np.full(100, 100)
+
+    result = helpers.smooth_path_gradients(gradients, distances, window_distance=500)
+
+    assert np.sum(result * distances) == pytest.approx(np.sum(gradients * distances))
+    assert np.all((0 <= result) & (result <= 0.05))
+    assert np.allclose(result[10:90], 0.02, atol=0.005)
+
+
+def test_decimate():
+    input_array = np.sin(np.linspace(0, 20, 10000))
+    input_array[1234] = 5
+    input_array[8765] = -5
+
+    envelope = helpers.decimate(input_array, 100, mode="minmax")
+
+    assert len(envelope) == 200
+    assert np.max(envelope) == 5
+    assert np.min(envelope) == -5
+
+    assert np.allclose(helpers.decimate(np.arange(10), 5, mode="mean"), [0.5, 2.5, 4.5, 6.5, 8.5])
+    assert np.all(helpers.decimate(np.arange(9), 3) == np.array([1, 4, 7]))
+    assert np.all(helpers.decimate(np.arange(3), 5) == np.arange(3))
+
+
+def test_get_array_directional_wind_speed():
+    # a route heading north, then east, then south, with a 10m/s wind blowing from the north throughout
+    route_coords = np.array([[49.0, -123.0], [49.1, -123.0], [49.1, -122.9], [49.0, -122.9]])
+    path_bearings = helpers.calculate_path_bearings(route_coords)
+
+    wind_speeds = np.full(4, 10)
+    wind_directions = np.zeros(4)
+
+    result = helpers.get_array_directional_wind_speed(path_bearings, wind_speeds, wind_directions)
+
+    # a full headwind heading north, a crosswind heading east, and a full tailwind heading south
+    assert result[0] == pytest.approx(10)
+    assert abs(result[1]) < 0.1
+    assert result[2] == pytest.approx(-10)
+    assert result[3] == pytest.approx(-10)
+
+
+def test_calculate_total_stop_time():
+    stop_durations = np.array([900, 1800, 0, 2700])
+
+    assert helpers.calculate_total_stop_time(stop_durations) == 5400
+    assert helpers.calculate_total_stop_time([]) == 0
+
+    # with an overnight hold from 8pm to 9am
+    hold_ranges = np.array([[20 * 3600, 33 * 3600]])
+    assert helpers.calculate_total_stop_time(stop_durations, hold_ranges) == 5400 + 13 * 3600
+
+
+def test_find_stranded_index():
+    # the battery runs flat at the fourth time step while the car is still driving
+    state_of_charge = np.array([0.3, 0.2, 0.1, 0, 0, 0])
+    speed_kmh = np.array([60, 60, 60, 60, 60, 0])
+
+    assert helpers.find_stranded_index(state_of_charge, speed_kmh) == 3
+
+    # stopping as the battery runs flat is feasible
+    speed_kmh = np.array([60, 60, 60, 0, 0, 0])
+
+    assert helpers.find_stranded_index(state_of_charge, speed_kmh) == -1
+
+
+def test_calculate_total_stop_time_overlapping_holds():
+    # a hold nested inside another, and two holds that overlap by an hour
+    hold_ranges = np.array([[0, 10 * 3600], [2 * 3600, 5 * 3600], [20 * 3600, 30 * 3600], [29 * 3600, 33 * 3600]])
+
+    total_stop_time = helpers.calculate_total_stop_time([], hold_ranges)
+
+    assert total_stop_time == 10 * 3600 + 13 * 3600
+
+    # agrees with the time that apply_overnight_holds actually holds the car for
+    held_speeds = helpers.apply_overnight_holds(np.full(40 * 3600, 50), hold_ranges)
+    assert total_stop_time == np.count_nonzero(held_speeds == 0)
@@ -0,0 +1,59 @@
+import numpy as np
+import simulation
+
+
+def test_interpolate_motor_efficiency():
+    angular_speed_breakpoints = np.array([0, 100])
+    torque_breakpoints = np.array([0, 10])
+    efficiency_table = np.array([[0.80, 0.90],
+                                 [0.85, 0.95]])
+
+    angular_speeds = np.array([0, 100, 0, 100, 50, 200])
+    torques = np.array([0, 0, 10, 10, 5, -5])
+
+    result = simulation.BasicMotor.interpolate_motor_efficiency(angular_speeds, torques, angular_speed_breakpoints,
+                                                                torque_breakpoints, efficiency_table)
+
+    # exact at the grid nodes, averaged at the centre of the cell, and clamped outside of the table
+    assert np.allclose(result, [0.80, 0.85, 0.90, 0.95, 0.875, 0.85])
+
+
+def test_apply_efficiency():
+    powers = np.array([1000, -1000, 0, 500])
+
+    assert np.allclose(simulation.BasicMotor.apply_efficiency(powers, np.ones(4)), powers)
+    assert np.allclose(simulation.BasicMotor.apply_efficiency(powers, np.full(4, 0.5)), [2000, -500, 0, 1000])
+
+    # a zero efficiency is clamped rather than dividing by zero
+    result = simulation.BasicMotor.apply_efficiency(powers, np.zeros(4), min_efficiency=0.1)
+    assert np.allclose(result, [10000, -100, 0, 5000])
+
+
+def test_calculate_motor_rpm():
+    speed_kmh = np.array([0, 36, 72])
+
+    # 10m/s on 0.2032m wheels is 49.2rad/s at the wheel
+    rpm = simulation.BasicMotor.calculate_motor_rpm(speed_kmh, tire_radius=0.2032)
+    geared_rpm = simulation.BasicMotor.calculate_motor_rpm(speed_kmh, tire_radius=0.2032, gear_ratio=4)
+
+    assert np.allclose(rpm, [0, 469.95, 939.9], rtol=1e-4)
+    assert np.allclose(geared_rpm, 4 * rpm)
+
+    assert np.allclose(simulation.BasicMotor.calculate_speed_from_rpm(rpm, tire_radius=0.2032), speed_kmh)
+    assert np.allclose(simulation.BasicMotor.calculate_speed_from_rpm(geared_rpm, tire_radius=0.2032, gear_ratio=4),
+                       speed_kmh)
+
+
+def test_calculate_energy_in_tick_independent():
+    motor = simulation.BasicMotor()
+
+    # 100s at a constant 60km/h on flat ground, in 1s, 10s and mixed steps
+    one_second_energy = motor.calculate_energy_in(np.full(100, 60.0), np.zeros(100), np.zeros(100), 1)
+    ten_second_energy = motor.calculate_energy_in(np.full(10, 60.0), np.zeros(10), np.zeros(10), 10)
+
+    mixed_ticks = np.array([10, 20, 30, 40])
+    mixed_energy = motor.calculate_energy_in(np.full(4, 60.0), np.zeros(4), np.zeros(4), mixed_ticks)
+
+    assert np.isclose(np.sum(ten_second_energy), np.sum(one_second_energy))
+    assert np.isclose(np.sum(mixed_energy), np.sum(one_second_energy))
+    assert np.allclose(mixed_energy / mixed_ticks, one_second_energy[0])
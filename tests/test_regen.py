@@ -0,0 +1,12 @@
+import numpy as np
+import simulation
+
+
+def test_calculate_regen_energy():
+    # motoring on the flat, a mild descent, then a steep descent that exceeds the regen power cap
+    tractive_powers = np.array([1000, -2000, -20000])
+
+    result = simulation.BasicRegen.calculate_regen_energy(tractive_powers, regen_efficiency=0.5,
+                                                          max_regen_power=5000, tick=1)
+
+    assert np.allclose(result, [1000, -1000, -5000])
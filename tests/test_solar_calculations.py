@@ -0,0 +1,90 @@
+import simulation
+import numpy as np
+import pytest
+
+
+@pytest.fixture
+def solar_calculations():
+    return simulation.SolarCalculations()
+
+
+def test_calculate_plane_of_array_irradiance(solar_calculations):
+    DNI = np.array([800, 900])
+    DHI = np.array([80, 90])
+    zenith_angles = np.array([30, 60])
+    azimuth_angles = np.array([120, 200])
+
+    # a flat array receives the GHI
+    result = solar_calculations.calculate_plane_of_array_irradiance(DNI, DHI, zenith_angles, azimuth_angles,
+                                                                    array_tilt=0, array_azimuth=180)
+    GHI = DNI * np.cos(np.radians(zenith_angles)) + DHI
+
+    assert np.allclose(result, GHI)
+
+    # an array tilted to face the sun receives all of the DNI
+    result = solar_calculations.calculate_plane_of_array_irradiance(DNI[0], DHI[0], zenith_angles[0],
+                                                                    azimuth_angles[0], array_tilt=30,
+                                                                    array_azimuth=120, albedo=0)
+
+    assert result == pytest.approx(DNI[0] + DHI[0] * (1 + np.cos(np.radians(30))) / 2)
+
+
+def test_decode_local_times(solar_calculations):
+    # 8:00 and 9:30 on the 3rd of August 2021
+    local_times = np.array([1627977600, 1627983000])
+
+    day_of_year, local_time = solar_calculations.decode_local_times(local_times, cache_key=1)
+
+    assert np.all(day_of_year == np.array([215, 215]))
+    assert np.allclose(local_time, [8, 9.5])
+
+    # a cache hit returns the arrays decoded by the first call without decoding them again
+    cached_day_of_year, cached_local_time = solar_calculations.decode_local_times(local_times, cache_key=1)
+
+    assert cached_day_of_year is day_of_year
+    assert cached_local_time is local_time
+
+    # the least recently used keys are evicted once the cache is full
+    for cache_key in range(2, solar_calculations.decoded_times_cache_size + 2):
+        solar_calculations.decode_local_times(local_times, cache_key=cache_key)
+
+    assert 1 not in solar_calculations.decoded_times_cache
+
+
+def test_decompose_GHI(solar_calculations):
+    # reference values from pvlib.irradiance.erbs with a solar constant of 1367 W/m2
+    GHI = np.array([800, 100, 50])
+    zenith_angles = np.array([30, 30, 95])
+
+    DNI, DHI = solar_calculations.decompose_GHI(GHI, 1367, zenith_angles)
+
+    # clear sky (clearness index ~0.68) and overcast (clearness index ~0.08)
+    assert DNI[0] == pytest.approx(660.8, rel=0.02)
+    assert DHI[0] == pytest.approx(227.7, rel=0.02)
+    assert DNI[1] == pytest.approx(0.88, rel=0.02)
+    assert DHI[1] == pytest.approx(99.24, rel=0.02)
+
+    # once the sun has set, all of the remaining irradiance is diffuse
+    assert DNI[2] == 0
+    assert 0 <= DHI[2] <= GHI[2]
+
+
+def test_calculate_clear_sky_GHI(solar_calculations):
+    # reference values from pvlib.clearsky.haurwitz
+    zenith_angles = np.array([0, 30, 60, 85, 90, 120])
+
+    result = solar_calculations.calculate_clear_sky_GHI(zenith_angles)
+
+    assert np.allclose(result[:4], [1035.09, 888.27, 487.89, 48.63], rtol=1e-3)
+    assert np.all(result[4:] == 0)
+
+
+def test_calculate_cloud_attenuated_GHI(solar_calculations):
+    clear_sky_GHI = np.full(4, 1000)
+    cloud_cover = np.array([0, 0.5, 1, 1.2])
+
+    result = solar_calculations.calculate_cloud_attenuated_GHI(clear_sky_GHI, cloud_cover)
+
+    # a clear sky is unaffected, half cover barely attenuates, and full cover strongly attenuates
+    assert np.allclose(result, [1000, 1000 * (1 - 0.75 * 0.5 ** 3.4), 250, 250])
+    assert result[1] > 900
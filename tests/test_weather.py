@@ -0,0 +1,152 @@
+import simulation
+import numpy as np
+import pytest
+
+
+@pytest.fixture
+def weather():
+    # Builds a WeatherForecasts object around a small synthetic forecast so that no OpenWeather API calls are made
+
+    weather = simulation.WeatherForecasts.__new__(simulation.WeatherForecasts)
+
+    # 3 coordinates along the equator, each with an hourly forecast for 4 hours
+    weather_forecast = np.zeros((3, 4, 9))
+    weather_forecast[:, :, 1] = np.array([0, 0.1, 0.2])[:, np.newaxis]
+    weather_forecast[:, :, 2] = np.arange(4) * 3600
+    weather_forecast[:, :, 4] = np.arange(4) * 3600
+
+    # wind speeds encode the (coordinate, time) pair that they belong to
+    weather_forecast[:, :, 5] = np.arange(3)[:, np.newaxis] * 10 + np.arange(4)
+
+    weather.weather_forecast = weather_forecast
+
+    return weather
+
+
+def test_calculate_flattened_weather_indices(weather):
+    test_indices = np.array([0, 1, 2, 2])
+    test_timestamps = np.array([0, 3500, 7300, 10000])
+
+    result = weather.calculate_flattened_weather_indices(test_indices, test_timestamps)
+
+    assert np.all(result == np.array([0, 5, 10, 11]))
+
+    flattened_weather = weather.weather_forecast.reshape(-1, 9)[result]
+    assert np.all(flattened_weather == weather.get_weather_forecast_in_time(test_indices, test_timestamps))
+
+
+def test_calculate_closest_timestamp_indices_rejects_nan(weather):
+    weather.weather_forecast[:, 2, 4] = np.nan
+
+    with pytest.raises(simulation.common.NonFiniteInputError) as error:
+        weather.calculate_closest_timestamp_indices(np.array([0, 1]), np.array([0, 3600]))
+
+    assert error.value.array_name == "dt_local_array"
+    assert error.value.index == 2
+
+
+def test_resample_weather():
+    source_timestamps = np.array([0, 3600, 10800])
+    source_values = np.array([2, 4, 10])
+
+    unix_timestamps = np.array([-100, 0, 1800, 3600, 7200, 10800, 20000])
+
+    result = simulation.WeatherForecasts.resample_weather(source_timestamps, source_values, unix_timestamps)
+
+    # exact at the forecasts, averaged half-way between them, and flat outside of the forecast range
+    assert np.allclose(result, [2, 2, 3, 4, 7, 10, 10])
+
+
+def test_calculate_bracketing_timestamp_indices(weather):
+    test_indices = np.array([0, 1, 1, 2, 2])
+    test_timestamps = np.array([1800, 3600, 9000, -5, 99999])
+
+    lower_indices, upper_indices = weather.calculate_bracketing_timestamp_indices(test_indices, test_timestamps)
+
+    assert np.all(lower_indices == np.array([0, 1, 2, 0, 3]))
+    assert np.all(upper_indices == np.array([1, 1, 3, 0, 3]))
+
+    forecast_times = weather.weather_forecast[0, :, 4]
+    assert np.all(forecast_times[lower_indices[:3]] <= test_timestamps[:3])
+    assert np.all(forecast_times[upper_indices[:3]] >= test_timestamps[:3])
+
+
+def test_calculate_closest_weather_indices_looped(weather):
+    # 5 coordinates along the equator, evenly spaced
+    weather_forecast = np.zeros((5, 4, 9))
+    weather_forecast[:, :, 1] = np.arange(5)[:, np.newaxis] * 0.1
+    weather.weather_forecast = weather_forecast
+
+    spacing = simulation.common.helpers.calculate_path_distances(weather_forecast[:, 0, 0:2])[0]
+    route_length = 4 * spacing
+
+    # two laps of the route
+    lap_distances = np.array([0.5, 2, 3, 3.9]) * spacing
+    test_distances = np.concatenate([lap_distances, lap_distances + route_length])
+
+    clamped_indices = weather.calculate_closest_weather_indices(test_distances)
+    looped_indices = weather.calculate_closest_weather_indices(test_distances, looped=True,
+                                                               route_length=route_length)
+
+    assert np.all(clamped_indices == np.array([0, 1, 2, 2, 2, 2, 2, 2]))
+    assert np.all(looped_indices == np.array([0, 1, 2, 2, 0, 1, 2, 2]))
+
+
+def test_calculate_closest_timestamp_indices_tie_break(weather):
+    test_indices = np.zeros(3, dtype=int)
+
+    # 1800 and 5400 are exactly halfway between two forecast times, while 4000 is not
+    test_timestamps = np.array([1800, 5400, 4000])
+
+    lowest_indices = weather.calculate_closest_timestamp_indices(test_indices, test_timestamps)
+    highest_indices = weather.calculate_closest_timestamp_indices(test_indices, test_timestamps, tie_break="highest")
+
+    assert np.all(lowest_indices == np.array([0, 1, 1]))
+    assert np.all(highest_indices == np.array([1, 2, 1]))
+
+
+def test_blend_weather():
+    forecast_values = np.array([10, 10, 10])
+    historical_values = np.array([4, 4, 4])
+
+    result = simulation.WeatherForecasts.blend_weather(forecast_values, historical_values, np.array([1, 0, 0.25]))
+
+    assert np.allclose(result, [10, 4, 5.5])
+
+    with pytest.raises(ValueError):
+        simulation.WeatherForecasts.blend_weather(forecast_values, historical_values, np.array([1, 1.5, 0]))
+
+
+def test_calculate_closest_weather_indices_non_monotonic(weather):
+    # 5 coordinates along the equator, evenly spaced
+    weather_forecast = np.zeros((5, 4, 9))
+    weather_forecast[:, :, 1] = np.arange(5)[:, np.newaxis] * 0.1
+    weather.weather_forecast = weather_forecast
+
+    spacing = simulation.common.helpers.calculate_path_distances(weather_forecast[:, 0, 0:2])[0]
+
+    # a tiny floating point dip in the cumulative distances must not send the index back to the start
+    test_distances = np.array([0.5, 2, 3, 3 - 1e-9, 3.9]) * spacing
+
+    result = weather.calculate_closest_weather_indices(test_distances)
+
+    assert np.all(result == np.array([0, 1, 2, 2, 2]))
+
+
+def test_calculate_closest_weather_indices_looped_default_route_length(weather):
+    # 4 coordinates on the corners of a square, so the lap closes back at the first coordinate
+    weather_forecast = np.zeros((4, 4, 9))
+    weather_forecast[:, :, 0:2] = np.array([[0, 0], [0, 0.1], [0.1, 0.1], [0.1, 0]])[:, np.newaxis, :]
+    weather.weather_forecast = weather_forecast
+
+    spacing = simulation.common.helpers.calculate_path_distances(weather_forecast[:, 0, 0:2])[0]
+
+    # the lap is 4 sides long, so 3.5 sides in the car has not yet wrapped back to the start
+    test_distances = np.array([0.5, 2, 3.5, 4.5]) * spacing
+
+    default_indices = weather.calculate_closest_weather_indices(test_distances, looped=True)
+    explicit_indices = weather.calculate_closest_weather_indices(test_distances, looped=True,
+                                                                 route_length=4 * spacing)
+
+    assert np.all(default_indices == explicit_indices)
+    assert np.all(default_indices == np.array([0, 1, 1, 0]))